@@ -0,0 +1,237 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/sapcc/go-bits/logg"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// AdmissionEventsConfiguration appears in type Configuration.
+type AdmissionEventsConfiguration struct {
+	Enabled bool `json:"enabled"`
+	// If empty, events are collected from all namespaces.
+	Namespace string `json:"namespace"`
+	// Only events observed within this time window are reported.
+	Window Duration `json:"window"`
+}
+
+// AdmissionEventRecord describes an AdmissionEvent that was observed for a certain constraint.
+type AdmissionEventRecord struct {
+	ConstraintKind string
+	ConstraintName string
+	Event          doop.AdmissionEvent
+}
+
+// admissionObservation is a single increase of an admission event's count, as observed by AdmissionEventWatcher.
+type admissionObservation struct {
+	Time   time.Time
+	Record AdmissionEventRecord
+}
+
+// admissionEventState is what AdmissionEventWatcher remembers about each Event object.
+type admissionEventState struct {
+	Count    int
+	LastSeen time.Time
+}
+
+// AdmissionEventWatcher watches the Kubernetes events that Gatekeeper emits
+// when it denies or warns about an admission request (if Gatekeeper runs with
+// `--emit-admission-events`), and remembers them for the configured time window.
+type AdmissionEventWatcher struct {
+	client    kubernetes.Interface
+	namespace string
+	window    time.Duration
+
+	mutex        sync.Mutex
+	events       map[types.UID]admissionEventState
+	observations []admissionObservation
+}
+
+// NewAdmissionEventWatcher builds an AdmissionEventWatcher. It will not do anything until Sync() or Run() is called.
+func NewAdmissionEventWatcher(client kubernetes.Interface, cfg AdmissionEventsConfiguration) *AdmissionEventWatcher {
+	return &AdmissionEventWatcher{
+		client:    client,
+		namespace: cfg.Namespace,
+		window:    time.Duration(cfg.Window),
+		events:    make(map[types.UID]admissionEventState),
+	}
+}
+
+// Run watches for admission events until `ctx` expires.
+func (w *AdmissionEventWatcher) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := w.listAndWatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			logg.Error("while watching admission events: %s", err.Error())
+			// wait a bit before retrying to avoid overloading the Kubernetes API
+			select {
+			case <-ctx.Done():
+			case <-time.After(10 * time.Second):
+			}
+		}
+	}
+}
+
+// Sync lists all existing events once. This is called by Run(), but can
+// also be called on its own when only a single report shall be gathered.
+func (w *AdmissionEventWatcher) Sync(ctx context.Context) (resourceVersion string, err error) {
+	list, err := w.client.CoreV1().Events(w.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("cannot list events: %w", err)
+	}
+	for _, ev := range list.Items {
+		w.observe(ev)
+	}
+	return list.ResourceVersion, nil
+}
+
+func (w *AdmissionEventWatcher) listAndWatch(ctx context.Context) error {
+	resourceVersion, err := w.Sync(ctx)
+	if err != nil {
+		return err
+	}
+	watcher, err := w.client.CoreV1().Events(w.namespace).Watch(ctx, metav1.ListOptions{ResourceVersion: resourceVersion})
+	if err != nil {
+		return fmt.Errorf("cannot watch events: %w", err)
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case we, ok := <-watcher.ResultChan():
+			if !ok {
+				// the watch has expired -> the caller will restart it (since we keep
+				// track of counts per event, relisting will not count events twice)
+				return nil
+			}
+			switch we.Type {
+			case watch.Added, watch.Modified:
+				ev, ok := we.Object.(*corev1.Event)
+				if ok {
+					w.observe(*ev)
+				}
+			case watch.Error:
+				return fmt.Errorf("error during watch: %w", apierrors.FromObject(we.Object))
+			}
+		}
+	}
+}
+
+var admissionEventMessageRx = regexp.MustCompile(`(?s), Message: (.*)$`)
+
+func (w *AdmissionEventWatcher) observe(ev corev1.Event) {
+	record, ok := parseAdmissionEvent(ev)
+	if !ok {
+		return
+	}
+	eventTime := getEventTime(ev)
+	count := max(int(ev.Count), 1)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// Kubernetes deduplicates events by increasing the count on an existing Event object,
+	// so we only need to record the increase since the last time that we saw this object
+	state := w.events[ev.UID]
+	if count > state.Count {
+		record.Event.Count = count - state.Count
+		w.observations = append(w.observations, admissionObservation{eventTime, record})
+	}
+	w.events[ev.UID] = admissionEventState{Count: max(count, state.Count), LastSeen: eventTime}
+}
+
+// Records returns all admission events that were observed within the configured time window.
+// Repeated events for the same constraint, user and object are merged.
+func (w *AdmissionEventWatcher) Records(now time.Time) []AdmissionEventRecord {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// forget about everything that is outside the window
+	cutoff := now.Add(-w.window)
+	for uid, state := range w.events {
+		if state.LastSeen.Before(cutoff) {
+			delete(w.events, uid)
+		}
+	}
+	var result []AdmissionEventRecord
+	keptObservations := w.observations[:0]
+OBSERVATION:
+	for _, obs := range w.observations {
+		if obs.Time.Before(cutoff) {
+			continue
+		}
+		keptObservations = append(keptObservations, obs)
+
+		for idx, other := range result {
+			if other.ConstraintKind == obs.Record.ConstraintKind && other.ConstraintName == obs.Record.ConstraintName && other.Event.IsSameAs(obs.Record.Event) {
+				result[idx].Event.MergeFrom(obs.Record.Event)
+				continue OBSERVATION
+			}
+		}
+		result = append(result, obs.Record)
+	}
+	w.observations = keptObservations
+	return result
+}
+
+// parseAdmissionEvent extracts the relevant information from an event emitted by Gatekeeper.
+// If the event does not describe an admission request, false is returned.
+func parseAdmissionEvent(ev corev1.Event) (AdmissionEventRecord, bool) {
+	a := ev.Annotations
+	if a["process"] != "admission" || a["constraint_kind"] == "" || a["constraint_name"] == "" {
+		return AdmissionEventRecord{}, false
+	}
+
+	// Gatekeeper puts the violation message at the end of the event message;
+	// if it has an object identity prefix, we extract it in the same way as for audit violations
+	message := ev.Message
+	match := admissionEventMessageRx.FindStringSubmatch(message)
+	if match != nil {
+		message = match[1]
+	}
+	message, objectIdentity := splitObjectIdentity(message)
+
+	return AdmissionEventRecord{
+		ConstraintKind: a["constraint_kind"],
+		ConstraintName: a["constraint_name"],
+		Event: doop.AdmissionEvent{
+			Kind:              a["resource_kind"],
+			Name:              a["resource_name"],
+			Namespace:         a["resource_namespace"],
+			Message:           message,
+			ObjectIdentity:    objectIdentity,
+			User:              a["request_username"],
+			EnforcementAction: a["constraint_action"],
+			Count:             max(int(ev.Count), 1),
+			LastSeen:          getEventTime(ev).UTC().Format(time.RFC3339),
+		},
+	}, true
+}
+
+func getEventTime(ev corev1.Event) time.Time {
+	switch {
+	case !ev.LastTimestamp.IsZero():
+		return ev.LastTimestamp.Time
+	case !ev.EventTime.IsZero():
+		return ev.EventTime.Time
+	default:
+		return ev.CreationTimestamp.Time
+	}
+}
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.xyrillian.de/gg/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func makeAdmissionEvent(uid string, count int32, lastTimestamp time.Time) corev1.Event {
+	return corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			UID: types.UID(uid),
+			Annotations: map[string]string{
+				"process":            "admission",
+				"constraint_kind":    "GkImageTag",
+				"constraint_name":    "imagetag",
+				"constraint_action":  "deny",
+				"resource_kind":      "Pod",
+				"resource_namespace": "default",
+				"resource_name":      "foo",
+				"request_username":   "alice",
+			},
+		},
+		Message:       "Admission webhook \"validation.gatekeeper.sh\" denied request, Message: image uses latest tag",
+		Count:         count,
+		LastTimestamp: metav1.NewTime(lastTimestamp),
+	}
+}
+
+func TestAdmissionEventWatcher(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	w := NewAdmissionEventWatcher(nil, AdmissionEventsConfiguration{Window: Duration(1 * time.Hour)})
+	expectRecord := func(count int, lastSeen time.Time) []AdmissionEventRecord {
+		return []AdmissionEventRecord{{
+			ConstraintKind: "GkImageTag",
+			ConstraintName: "imagetag",
+			Event: doop.AdmissionEvent{
+				Kind:              "Pod",
+				Name:              "foo",
+				Namespace:         "default",
+				Message:           "image uses latest tag",
+				User:              "alice",
+				EnforcementAction: "deny",
+				Count:             count,
+				LastSeen:          lastSeen.Format(time.RFC3339),
+			},
+		}}
+	}
+
+	// when Kubernetes deduplicates repeated events into one Event object with increasing count,
+	// only the increase is counted, so seeing the same Event object again (e.g. after a relist) does not count it twice
+	w.observe(makeAdmissionEvent("a", 1, t0))
+	w.observe(makeAdmissionEvent("a", 3, t0.Add(10*time.Minute)))
+	w.observe(makeAdmissionEvent("a", 3, t0.Add(10*time.Minute)))
+	// separate Event objects for the same constraint, user and object are merged
+	w.observe(makeAdmissionEvent("b", 2, t0.Add(20*time.Minute)))
+	assert.Equal(t, w.Records(t0.Add(30*time.Minute)), expectRecord(5, t0.Add(20*time.Minute)))
+
+	// events age out of the window individually: here, the first occurrence of "a" is outside of the window
+	assert.Equal(t, w.Records(t0.Add(65*time.Minute)), expectRecord(4, t0.Add(20*time.Minute)))
+	assert.Equal(t, len(w.observations), 2)
+
+	// once everything is outside the window, nothing is reported, and the watcher forgets about the Event objects
+	assert.Equal(t, len(w.Records(t0.Add(2*time.Hour))), 0)
+	assert.Equal(t, len(w.observations), 0)
+	assert.Equal(t, len(w.events), 0)
+}
@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/sapcc/go-bits/logg"
+)
+
+// AuditExportConfiguration appears in types Configuration and TargetConfiguration.
+type AuditExportConfiguration struct {
+	// The directory into which Gatekeeper's disk exporter writes audit results.
+	Path string `json:"path"`
+}
+
+// auditExportMessage is the format of each line in the files written by Gatekeeper's disk exporter.
+// Fields that we do not use are omitted here.
+type auditExportMessage struct {
+	ID        string `json:"id"`
+	EventType string `json:"eventType"`
+	// These fields refer to the constraint.
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// These fields refer to the violating object.
+	Message           string `json:"message"`
+	ResourceKind      string `json:"resourceKind"`
+	ResourceNamespace string `json:"resourceNamespace"`
+	ResourceName      string `json:"resourceName"`
+}
+
+// constraintRef identifies a constraint across all templates.
+type constraintRef struct {
+	Kind string
+	Name string
+}
+
+// AuditRun contains the violations from one completed Gatekeeper audit run,
+// as read from the files written by Gatekeeper's disk exporter.
+type AuditRun struct {
+	// The audit ID chosen by Gatekeeper. This is a timestamp in RFC3339 format.
+	ID         string
+	Violations map[constraintRef][]ConstraintViolation
+}
+
+// ReadLatestAuditRun finds the most recent completed audit run in the given directory and reads it.
+//
+// Gatekeeper's disk exporter writes each audit run into a separate file.
+// While the audit run is in progress, the file has the extension ".txt".
+// Once the audit run has completed, the file is renamed to have the extension ".log".
+func ReadLatestAuditRun(dirPath string) (AuditRun, error) {
+	paths, err := filepath.Glob(filepath.Join(dirPath, "*.log"))
+	if err != nil {
+		return AuditRun{}, fmt.Errorf("cannot list audit export files in %s: %w", dirPath, err)
+	}
+
+	// file mtimes are not reliable (they change when files are copied or restored),
+	// so the latest run is identified by the audit ID that Gatekeeper writes into each message
+	type candidate struct {
+		Path string
+		Time time.Time
+	}
+	var candidates []candidate
+	for _, path := range paths {
+		auditTime, err := readAuditRunTime(path)
+		if err != nil {
+			// empty or broken files (e.g. leftovers from log rotation) must not prevent us from using the other runs
+			logg.Error("skipping audit export file: %s", err.Error())
+			continue
+		}
+		candidates = append(candidates, candidate{path, auditTime})
+	}
+	slices.SortFunc(candidates, func(lhs, rhs candidate) int {
+		return rhs.Time.Compare(lhs.Time)
+	})
+
+	// if the latest run cannot be read in full, fall back to the next older one
+	for _, c := range candidates {
+		run, err := readAuditRunFile(c.Path)
+		if err != nil {
+			logg.Error("skipping audit export file: %s", err.Error())
+			continue
+		}
+		return run, nil
+	}
+	return AuditRun{}, fmt.Errorf("no usable completed audit run found in %s", dirPath)
+}
+
+// readAuditRunFile is readAuditRun for the audit export file at the given path.
+func readAuditRunFile(path string) (AuditRun, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return AuditRun{}, fmt.Errorf("cannot open audit export file: %w", err)
+	}
+	defer file.Close()
+	run, err := readAuditRun(file)
+	if err != nil {
+		return AuditRun{}, fmt.Errorf("while reading %s: %w", path, err)
+	}
+	return run, nil
+}
+
+// readAuditRunTime reads the audit ID from the first message in the given audit export file.
+func readAuditRunTime(path string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot open audit export file: %w", err)
+	}
+	defer file.Close()
+
+	var msg auditExportMessage
+	err = json.NewDecoder(file).Decode(&msg)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("while reading %s: %w", path, err)
+	}
+	auditTime, err := time.Parse(time.RFC3339, msg.ID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("while reading %s: invalid audit ID: %w", path, err)
+	}
+	return auditTime, nil
+}
+
+func readAuditRun(r io.Reader) (AuditRun, error) {
+	run := AuditRun{Violations: make(map[constraintRef][]ConstraintViolation)}
+	dec := json.NewDecoder(r)
+	for {
+		var msg auditExportMessage
+		err := dec.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			return run, nil
+		}
+		if err != nil {
+			return AuditRun{}, err
+		}
+		if run.ID == "" {
+			run.ID = msg.ID
+		}
+		if msg.EventType != "violation_audited" {
+			continue
+		}
+
+		// like for violations from the constraint status, enforcement actions and details are not
+		// carried into the report (see "Audit export" in the README for why)
+		ref := constraintRef{Kind: msg.Kind, Name: msg.Name}
+		run.Violations[ref] = append(run.Violations[ref], ConstraintViolation{
+			Kind:      msg.ResourceKind,
+			Name:      msg.ResourceName,
+			Namespace: msg.ResourceNamespace,
+			Message:   msg.Message,
+		})
+	}
+}
+
+// ApplyTo replaces the violations from the constraint's status with those from this audit run.
+func (run AuditRun) ApplyTo(c Constraint) Constraint {
+	c.Status.AuditTimestamp = run.ID
+	c.Status.Violations = run.Violations[constraintRef{Kind: c.Kind, Name: c.Metadata.Name}]
+	return c
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+)
+
+func TestReadLatestAuditRun(t *testing.T) {
+	// copy the fixtures such that the older audit run has the newer mtime, and the file names do not contain timestamps,
+	// to check that the latest run is identified by the audit ID within the files
+	dir := t.TempDir()
+	copyWithMtime := func(srcName, dstName string, mtime time.Time) {
+		buf := must.ReturnT(os.ReadFile(filepath.Join("fixtures/audit-export", srcName)))(t)
+		dstPath := filepath.Join(dir, dstName)
+		must.SucceedT(t, os.WriteFile(dstPath, buf, 0o666))
+		must.SucceedT(t, os.Chtimes(dstPath, mtime, mtime))
+	}
+	now := time.Now()
+	copyWithMtime("2023-08-01T09:00:00Z.log", "b.log", now)
+	copyWithMtime("2023-08-01T10:00:00Z.log", "a.log", now.Add(-1*time.Hour))
+	copyWithMtime("2023-08-01T11:00:00Z.txt", "c.txt", now.Add(1*time.Hour))
+
+	run := must.ReturnT(ReadLatestAuditRun(dir))(t)
+	assert.Equal(t, run.ID, "2023-08-01T10:00:00Z")
+
+	// broken files are skipped: empty files, files with unparseable audit IDs, and truncated files
+	// (the latter has the newest audit ID, so the previous run is used instead)
+	must.SucceedT(t, os.WriteFile(filepath.Join(dir, "d.log"), nil, 0o666))
+	must.SucceedT(t, os.WriteFile(filepath.Join(dir, "e.log"), []byte(`{"id":"foo","eventType":"audit_started"}`), 0o666))
+	must.SucceedT(t, os.WriteFile(filepath.Join(dir, "f.log"), []byte(`{"id":"2023-08-01T12:00:00Z","eventType":"audit_started"}`+"\n"+`{"id":"2023-08-01T12:00:00Z","eventTy`), 0o666))
+	run = must.ReturnT(ReadLatestAuditRun(dir))(t)
+	assert.Equal(t, run.ID, "2023-08-01T10:00:00Z")
+
+	// if no usable run remains, that is an error
+	must.SucceedT(t, os.Remove(filepath.Join(dir, "a.log")))
+	must.SucceedT(t, os.Remove(filepath.Join(dir, "b.log")))
+	_, err := ReadLatestAuditRun(dir)
+	if err == nil || !strings.Contains(err.Error(), "no usable completed audit run found") {
+		t.Errorf("expected error about missing audit run, but got %v", err)
+	}
+}
@@ -4,10 +4,12 @@
 package main
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/sapcc/go-bits/errext"
 	"github.com/sapcc/go-bits/regexpext"
@@ -15,17 +17,63 @@ import (
 
 // Configuration contains the contents of the config file.
 type Configuration struct {
-	ClusterIdentity map[string]string `json:"cluster_identity"`
-	Kubernetes      struct {
-		KubeconfigPath string `json:"kubeconfig"`
-		Context        string `json:"context"`
-	} `json:"kubernetes"`
-	Metrics struct {
+	AdmissionEvents AdmissionEventsConfiguration `json:"admission_events"`
+	AuditExport     AuditExportConfiguration     `json:"audit_export"`
+	ClusterIdentity map[string]string            `json:"cluster_identity"`
+	Definitions     struct {
+		IncludeSpecs bool `json:"include_specs"`
+	} `json:"definitions"`
+	Gatekeeper GatekeeperConfiguration `json:"gatekeeper"`
+	Kubernetes KubernetesConfiguration `json:"kubernetes"`
+	Metrics    struct {
 		ListenAddress string `json:"listen_address"`
+		// If true, violation counts from each report are exported as metrics.
+		Violations bool `json:"violations"`
 	} `json:"metrics"`
-	MergingRules    []Rule             `json:"merging_rules"`
-	ProcessingRules []Rule             `json:"processing_rules"`
-	Swift           SwiftConfiguration `json:"swift"`
+	MergingRules      []Rule                 `json:"merging_rules"`
+	Mutators          MutatorsConfiguration  `json:"mutators"`
+	ProcessingRules   []Rule                 `json:"processing_rules"`
+	Redaction         RedactionConfiguration `json:"redaction"`
+	Scope             ScopeConfiguration     `json:"scope"`
+	Swift             SwiftConfiguration     `json:"swift"`
+	Targets           []TargetConfiguration  `json:"targets"`
+	TargetConcurrency int                    `json:"target_concurrency"`
+
+	// A hash over the config file, for the report metadata. This is filled by ReadConfiguration().
+	hash string
+}
+
+// KubernetesConfiguration appears in types Configuration and TargetConfiguration.
+type KubernetesConfiguration struct {
+	KubeconfigPath string `json:"kubeconfig"`
+	Context        string `json:"context"`
+}
+
+// TargetConfiguration appears in type Configuration. It describes one of
+// multiple clusters that a single analyzer collects reports from.
+type TargetConfiguration struct {
+	AuditExport     AuditExportConfiguration `json:"audit_export"`
+	ClusterIdentity map[string]string        `json:"cluster_identity"`
+	Kubernetes      KubernetesConfiguration  `json:"kubernetes"`
+	ObjectName      string                   `json:"object_name"`
+}
+
+// Duration is a time.Duration that appears in the configuration as a string like "1h" or "30m".
+type Duration time.Duration
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *Duration) UnmarshalJSON(buf []byte) error {
+	var str string
+	err := json.Unmarshal(buf, &str)
+	if err != nil {
+		return err
+	}
+	val, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = Duration(val)
+	return nil
 }
 
 // Rule is a rule that can appear in `processing_rules` or `merging_rules`.
@@ -56,16 +104,96 @@ func ReadConfiguration(configPath string) (Configuration, error) {
 	}
 
 	// apply default values, check for universally required values
+	cfg.hash = fmt.Sprintf("%x", sha256.Sum256(buf))
+	if cfg.Gatekeeper.Namespace == "" {
+		cfg.Gatekeeper.Namespace = "gatekeeper-system"
+	}
+	if cfg.Gatekeeper.DeploymentName == "" {
+		cfg.Gatekeeper.DeploymentName = "gatekeeper-controller-manager"
+	}
 	if cfg.Metrics.ListenAddress == "" {
 		cfg.Metrics.ListenAddress = ":8080"
 	}
-	if len(cfg.ClusterIdentity) == 0 {
-		return Configuration{}, errors.New("missing required configuration value: cluster_identity")
+	if cfg.AdmissionEvents.Window <= 0 {
+		cfg.AdmissionEvents.Window = Duration(1 * time.Hour)
+	}
+	if cfg.Swift.History.Interval > 0 && cfg.Swift.History.Retention <= 0 {
+		cfg.Swift.History.Retention = Duration(30 * 24 * time.Hour)
+	}
+	if cfg.TargetConcurrency <= 0 {
+		cfg.TargetConcurrency = 4
+	}
+	errs := cfg.Redaction.validate()
+	errs.Append(cfg.Scope.validate())
+	if !errs.IsEmpty() {
+		return Configuration{}, fmt.Errorf("while parsing %s: %w", configPath, errs.JoinedError(", "))
+	}
+	if len(cfg.Targets) == 0 {
+		if len(cfg.ClusterIdentity) == 0 {
+			return Configuration{}, errors.New("missing required configuration value: cluster_identity")
+		}
+	} else {
+		errs := cfg.validateTargets()
+		if !errs.IsEmpty() {
+			return Configuration{}, fmt.Errorf("while parsing %s: %w", configPath, errs.JoinedError(", "))
+		}
 	}
 
 	return cfg, nil
 }
 
+func (cfg Configuration) validateTargets() (errs errext.ErrorSet) {
+	if cfg.AuditExport != (AuditExportConfiguration{}) {
+		errs.Addf("audit_export cannot be given when targets are configured")
+	}
+	if len(cfg.ClusterIdentity) != 0 {
+		errs.Addf("cluster_identity cannot be given when targets are configured")
+	}
+	if cfg.Kubernetes != (KubernetesConfiguration{}) {
+		errs.Addf("kubernetes cannot be given when targets are configured")
+	}
+	if cfg.Swift.ObjectName != "" {
+		errs.Addf("swift.object_name cannot be given when targets are configured")
+	}
+
+	isObjectNameSeen := make(map[string]bool, len(cfg.Targets))
+	for idx, target := range cfg.Targets {
+		if len(target.ClusterIdentity) == 0 {
+			errs.Addf("missing required configuration value: targets[%d].cluster_identity", idx)
+		}
+		if target.ObjectName == "" {
+			errs.Addf("missing required configuration value: targets[%d].object_name", idx)
+		} else {
+			if isObjectNameSeen[target.ObjectName] {
+				errs.Addf("duplicate value for targets[%d].object_name: %q", idx, target.ObjectName)
+			}
+			isObjectNameSeen[target.ObjectName] = true
+		}
+	}
+	return
+}
+
+// TargetConfigurations returns one Configuration for each cluster that this
+// analyzer collects reports from. If no `targets` are configured, the result
+// only contains this Configuration itself.
+func (cfg Configuration) TargetConfigurations() []Configuration {
+	if len(cfg.Targets) == 0 {
+		return []Configuration{cfg}
+	}
+
+	result := make([]Configuration, len(cfg.Targets))
+	for idx, target := range cfg.Targets {
+		tcfg := cfg
+		tcfg.Targets = nil
+		tcfg.AuditExport = target.AuditExport
+		tcfg.ClusterIdentity = target.ClusterIdentity
+		tcfg.Kubernetes = target.Kubernetes
+		tcfg.Swift.ObjectName = target.ObjectName
+		result[idx] = tcfg
+	}
+	return result
+}
+
 // ValidateRules returns a list of validation errors for the configuration's
 // MergingRules and ProcessingRules.
 func (cfg Configuration) ValidateRules() (errs errext.ErrorSet) {
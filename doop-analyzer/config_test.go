@@ -0,0 +1,27 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"go.xyrillian.de/gg/assert"
+)
+
+func TestValidateTargets(t *testing.T) {
+	cfg := Configuration{
+		Targets: []TargetConfiguration{
+			{ClusterIdentity: map[string]string{"name": "one"}, ObjectName: "cluster1.json"},
+			{ClusterIdentity: map[string]string{"name": "two"}},
+			{ObjectName: "cluster1.json"},
+		},
+	}
+	assert.Equal(t, cfg.validateTargets().JoinedError(", ").Error(), "missing required configuration value: targets[1].object_name, "+
+		"missing required configuration value: targets[2].cluster_identity, "+
+		`duplicate value for targets[2].object_name: "cluster1.json"`)
+
+	cfg.Targets[1].ObjectName = "cluster2.json"
+	cfg.Targets[2] = TargetConfiguration{ClusterIdentity: map[string]string{"name": "three"}, ObjectName: "cluster3.json"}
+	assert.Equal(t, cfg.validateTargets().IsEmpty(), true)
+}
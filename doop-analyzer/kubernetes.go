@@ -7,10 +7,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	k8sinternal "github.com/sapcc/gatekeeper-addons/internal/kubernetes"
@@ -29,8 +32,13 @@ var (
 
 // ClientSet provides access to the Gatekeeper API groups in k8s.
 type ClientSet struct {
+	core               kubernetes.Interface
 	constraintsV1Beta1 dynamic.Interface
 	templatesV1Beta1   dynamic.Interface
+	// only filled if mutators are enabled in the configuration
+	mutations dynamic.Interface
+	// only filled if admission events are enabled in the configuration
+	AdmissionEvents *AdmissionEventWatcher
 }
 
 // ClientSetInterface contains the methods that ClientSet provides. This
@@ -38,6 +46,11 @@ type ClientSet struct {
 type ClientSetInterface interface {
 	ListConstraintTemplates(ctx context.Context) ([]ConstraintTemplate, error)
 	ListConstraints(ctx context.Context, tmpl ConstraintTemplate) ([]Constraint, error)
+	ListAdmissionEvents(ctx context.Context) ([]AdmissionEventRecord, error)
+	ListMutators(ctx context.Context) ([]Mutator, error)
+	ListNamespaces(ctx context.Context) ([]corev1.Namespace, error)
+	GetGatekeeperVersion(ctx context.Context, cfg GatekeeperConfiguration) (string, error)
+	GetKubernetesVersion(ctx context.Context) (string, error)
 }
 
 // NewClientSet builds a ClientSet.
@@ -63,12 +76,31 @@ func NewClientSet(cfg Configuration) (cs ClientSet, err error) {
 		return client, err
 	}
 
+	cs.core, err = kubernetes.NewForConfig(kcfg)
+	if err != nil {
+		return ClientSet{}, fmt.Errorf("build core/v1 client: %w", err)
+	}
 	cs.constraintsV1Beta1, err = newClient(gvConstraintsV1Beta1)
 	if err != nil {
 		return ClientSet{}, err
 	}
 	cs.templatesV1Beta1, err = newClient(gvTemplatesV1Beta1)
-	return cs, err
+	if err != nil {
+		return ClientSet{}, err
+	}
+
+	if cfg.Mutators.Enabled {
+		// mutators are spread across multiple API versions, so this client is not bound to a specific one
+		cs.mutations, err = dynamic.NewForConfig(kcfg)
+		if err != nil {
+			return ClientSet{}, fmt.Errorf("build mutations.gatekeeper.sh client: %w", err)
+		}
+	}
+
+	if cfg.AdmissionEvents.Enabled {
+		cs.AdmissionEvents = NewAdmissionEventWatcher(cs.core, cfg.AdmissionEvents)
+	}
+	return cs, nil
 }
 
 // ConstraintTemplate is the unpacked form of `kind: ConstraintTemplate`.
@@ -87,6 +119,25 @@ type ConstraintTemplate struct {
 		// TODO We could report Rego parse errors.
 		Created bool `json:"created"`
 	} `json:"status"`
+	// RawSpec contains the entire `spec` section in unparsed form. It is filled by UnmarshalJSON().
+	RawSpec map[string]any `json:"-"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *ConstraintTemplate) UnmarshalJSON(buf []byte) error {
+	// the type alias is required to avoid infinite recursion into this method
+	type plainConstraintTemplate ConstraintTemplate
+	err := json.Unmarshal(buf, (*plainConstraintTemplate)(t))
+	if err != nil {
+		return err
+	}
+
+	var data struct {
+		Spec map[string]any `json:"spec"`
+	}
+	err = json.Unmarshal(buf, &data)
+	t.RawSpec = data.Spec
+	return err
 }
 
 // ListConstraintTemplates lists all constraint templates.
@@ -116,6 +167,7 @@ func (cs ClientSet) ListConstraintTemplates(ctx context.Context) ([]ConstraintTe
 type Constraint struct {
 	Kind     string            `json:"kind"`
 	Metadata metav1.ObjectMeta `json:"metadata"`
+	Spec     map[string]any    `json:"spec"`
 	Status   struct {
 		//TODO: We could parse `json:"byPod"` to report on whether Gatekeeper is functioning correctly.
 		AuditTimestamp string                `json:"auditTimestamp"`
@@ -159,3 +211,21 @@ func (cs ClientSet) ListConstraints(ctx context.Context, tmpl ConstraintTemplate
 	}
 	return result, nil
 }
+
+// ListAdmissionEvents returns all admission events observed within the configured time window.
+// If admission events are not enabled in the configuration, nothing is returned.
+func (cs ClientSet) ListAdmissionEvents(ctx context.Context) ([]AdmissionEventRecord, error) {
+	if cs.AdmissionEvents == nil {
+		return nil, nil
+	}
+	return cs.AdmissionEvents.Records(time.Now()), nil
+}
+
+// ListNamespaces lists all namespaces.
+func (cs ClientSet) ListNamespaces(ctx context.Context) ([]corev1.Namespace, error) {
+	list, err := cs.core.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list namespaces: %w", err)
+	}
+	return list.Items, nil
+}
@@ -7,9 +7,12 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -19,6 +22,7 @@ import (
 	"github.com/sapcc/go-bits/logg"
 	"github.com/sapcc/go-bits/must"
 	"github.com/sapcc/go-bits/osext"
+	"github.com/sapcc/go-bits/syncext"
 	"go.uber.org/automaxprocs/maxprocs"
 
 	"github.com/sapcc/gatekeeper-addons/internal/doop"
@@ -55,23 +59,63 @@ func main() {
 }
 
 var (
-	metricLastSuccessfulReport = prometheus.NewGauge(prometheus.GaugeOpts{
+	metricLastSuccessfulReport = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "doop_analyzer_last_successful_report",
 		Help: "UNIX timestamp in seconds when last report was submitted.",
-	})
-	metricReportDurationSecs = prometheus.NewGauge(prometheus.GaugeOpts{
+	}, []string{"cluster"})
+	metricReportFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "doop_analyzer_report_failures_total",
+		Help: "How many times a report could not be collected or submitted.",
+	}, []string{"cluster"})
+	metricReportDurationSecs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "doop_analyzer_report_duration_secs",
 		Help: "How long it took to collect and submit the last report, in seconds.",
-	})
+	}, []string{"cluster"})
+	metricRedactions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "doop_analyzer_redactions",
+		Help: "How many spans were redacted in the last report, by redaction rule or detector.",
+	}, []string{"cluster", "rule"})
+	metricViolations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "doop_analyzer_violations",
+		Help: "Number of violations in the last report, by constraint.",
+	}, []string{"cluster", "template_kind", "constraint_name", "severity"})
+	metricViolationGroups = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "doop_analyzer_violation_groups",
+		Help: "Number of violation groups in the last report, by constraint.",
+	}, []string{"cluster", "template_kind", "constraint_name", "severity"})
 )
 
+// analyzerTarget holds the configuration and Kubernetes client for one of the clusters that we collect reports from.
+type analyzerTarget struct {
+	Config Configuration
+	// nil until connect() succeeds
+	ClientSet ClientSetInterface
+	// how long the previous upload took (this is reported in the next report's metadata)
+	LastUploadDuration time.Duration
+	// when the report for the last history snapshot was started (only if history snapshots are enabled)
+	LastSnapshotTime time.Time
+}
+
 func taskRun(ctx context.Context, configPath string) {
 	prometheus.MustRegister(metricLastSuccessfulReport)
+	prometheus.MustRegister(metricReportFailures)
 	prometheus.MustRegister(metricReportDurationSecs)
+	prometheus.MustRegister(metricRedactions)
 
 	cfg := must.Return(ReadConfiguration(configPath))
-	cs := must.Return(NewClientSet(cfg))
+	if cfg.Metrics.Violations {
+		prometheus.MustRegister(metricViolations)
+		prometheus.MustRegister(metricViolationGroups)
+	}
 	must.Succeed(cfg.Swift.Connect(ctx))
+	var targets []analyzerTarget
+	for _, tcfg := range cfg.TargetConfigurations() {
+		must.Succeed(tcfg.Swift.CheckObjectName())
+		// the Kubernetes client is built by the first sendReport() call, so that errors are isolated to this target
+		targets = append(targets, analyzerTarget{Config: tcfg})
+		// initialize the failure counter, so that it is visible before the first failure
+		metricReportFailures.WithLabelValues(tcfg.Swift.ObjectName).Add(0)
+	}
 
 	// start HTTP server for Prometheus metrics
 	mux := http.NewServeMux()
@@ -80,47 +124,151 @@ func taskRun(ctx context.Context, configPath string) {
 		must.Succeed(httpext.ListenAndServeContext(ctx, cfg.Metrics.ListenAddress, mux))
 	}()
 
-	// send a report immediately, then once a minute
-	sendReport(ctx, cfg, cs)
+	// send reports immediately, then once a minute
+	sem := syncext.NewSemaphore(cfg.TargetConcurrency)
+	sendReports(ctx, targets, sem)
 	ticker := time.NewTicker(1 * time.Minute)
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			sendReport(ctx, cfg, cs)
+			sendReports(ctx, targets, sem)
 		}
 	}
 }
 
-func sendReport(ctx context.Context, cfg Configuration, cs ClientSetInterface) {
+func sendReports(ctx context.Context, targets []analyzerTarget, sem *syncext.Semaphore) {
+	// errors are only logged (and visible in the metrics) to ensure that
+	// one broken cluster does not prevent reports from other clusters
+	var wg sync.WaitGroup
+	for idx := range targets {
+		t := &targets[idx]
+		wg.Go(func() {
+			sem.Run(func() {
+				err := sendReport(ctx, t)
+				if err != nil {
+					logg.Error("could not send report for %s: %s", t.Config.Swift.ObjectName, err.Error())
+					metricReportFailures.WithLabelValues(t.Config.Swift.ObjectName).Inc()
+				}
+			})
+		})
+	}
+	wg.Wait()
+}
+
+// connect builds the Kubernetes client for this target, unless this was already done successfully.
+// If this fails (e.g. because of a broken kubeconfig), it is retried with the next report.
+func (t *analyzerTarget) connect(ctx context.Context) error {
+	if t.ClientSet != nil {
+		return nil
+	}
+	cs, err := NewClientSet(t.Config)
+	if err != nil {
+		return err
+	}
+	if cs.AdmissionEvents != nil {
+		go cs.AdmissionEvents.Run(ctx)
+	}
+	t.ClientSet = cs
+	return nil
+}
+
+func sendReport(ctx context.Context, t *analyzerTarget) error {
+	cfg := t.Config
 	start := time.Now()
 
-	report := must.Return(GatherReport(ctx, cfg, cs))
+	err := t.connect(ctx)
+	if err != nil {
+		return err
+	}
+	report, err := GatherReport(ctx, cfg, t.ClientSet)
+	if err != nil {
+		return err
+	}
 	ProcessReport(&report, cfg)
-	must.Succeed(cfg.Swift.SendReport(ctx, report))
+	// violation metrics are updated before the upload, so that in-cluster alerting keeps working while Swift is unavailable
+	if cfg.Metrics.Violations {
+		updateViolationMetrics(cfg.Swift.ObjectName, report)
+	}
+	report.Meta.Durations.UploadSecs = t.LastUploadDuration.Seconds()
+	uploadStart := time.Now()
+	err = cfg.Swift.SendReport(ctx, report)
+	if err != nil {
+		return err
+	}
 
 	end := time.Now()
+	t.LastUploadDuration = end.Sub(uploadStart)
+
+	interval := time.Duration(cfg.Swift.History.Interval)
+	if interval > 0 && start.Sub(t.LastSnapshotTime) >= interval {
+		// a failed snapshot does not invalidate the report itself; it will just be retried with the next report
+		err := cfg.Swift.SendHistorySnapshot(ctx, report, start)
+		if err == nil {
+			t.LastSnapshotTime = start
+		} else {
+			logg.Error("could not send history snapshot for %s: %s", cfg.Swift.ObjectName, err.Error())
+		}
+	}
 	duration := end.Sub(start)
-	metricLastSuccessfulReport.Set(float64(end.Unix()))
-	metricReportDurationSecs.Set(duration.Seconds())
-	logg.Info("report uploaded in %g seconds", duration.Seconds())
+	clusterName := cfg.Swift.ObjectName
+	metricLastSuccessfulReport.WithLabelValues(clusterName).Set(float64(end.Unix()))
+	metricReportDurationSecs.WithLabelValues(clusterName).Set(duration.Seconds())
+	metricRedactions.DeletePartialMatch(prometheus.Labels{"cluster": clusterName})
+	for rule, count := range report.Redactions {
+		metricRedactions.WithLabelValues(clusterName, rule).Set(float64(count))
+	}
+	logg.Info("report for %s uploaded in %g seconds", clusterName, duration.Seconds())
+	return nil
+}
+
+// updateViolationMetrics replaces the violation metrics for the given cluster with the counts from the given processed report.
+func updateViolationMetrics(clusterName string, report doop.Report) {
+	metricViolations.DeletePartialMatch(prometheus.Labels{"cluster": clusterName})
+	metricViolationGroups.DeletePartialMatch(prometheus.Labels{"cluster": clusterName})
+	for _, rt := range report.Templates {
+		for _, rc := range rt.Constraints {
+			labels := []string{clusterName, rt.Kind, rc.Name, rc.Metadata.Severity}
+			count := 0
+			for _, vg := range rc.ViolationGroups {
+				count += len(vg.Instances)
+			}
+			metricViolations.WithLabelValues(labels...).Set(float64(count))
+			metricViolationGroups.WithLabelValues(labels...).Set(float64(len(rc.ViolationGroups)))
+		}
+	}
 }
 
 func taskCollectOnce(ctx context.Context, configPath string) {
 	cfg := must.Return(ReadConfiguration(configPath))
-	cs := must.Return(NewClientSet(cfg))
-	report := must.Return(GatherReport(ctx, cfg, cs))
-	printJSON(report)
+	for _, tcfg := range cfg.TargetConfigurations() {
+		cs := must.Return(NewClientSet(tcfg))
+		if cs.AdmissionEvents != nil {
+			// without a long-running watch, we can only report the events that currently exist
+			must.Return(cs.AdmissionEvents.Sync(ctx))
+		}
+		report := must.Return(GatherReport(ctx, tcfg, cs))
+		printJSON(report)
+	}
 }
 
 func taskProcessOnce(_ context.Context, configPath string) {
 	cfg := must.Return(ReadConfiguration(configPath))
 	cfg.ValidateRules().LogFatalIfError()
-	var report doop.Report
-	must.Succeed(json.NewDecoder(os.Stdin).Decode(&report))
-	ProcessReport(&report, cfg)
-	printJSON(report)
+
+	// when collecting from multiple targets, collect-once prints multiple reports in a row
+	dec := json.NewDecoder(os.Stdin)
+	for {
+		var report doop.Report
+		err := dec.Decode(&report)
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		must.Succeed(err)
+		ProcessReport(&report, cfg)
+		printJSON(report)
+	}
 }
 
 func printJSON(data any) {
@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestUpdateViolationMetrics(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(metricViolations, metricViolationGroups)
+	getMetrics := func() []string {
+		rec := httptest.NewRecorder()
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		var result []string
+		for line := range strings.Lines(rec.Body.String()) {
+			if !strings.HasPrefix(line, "#") {
+				result = append(result, strings.TrimSpace(line))
+			}
+		}
+		return result
+	}
+
+	makeReport := func(podNames ...string) doop.Report {
+		report := doop.Report{Templates: []doop.ReportForTemplate{{
+			Kind: "GkImageTag",
+			Constraints: []doop.ReportForConstraint{{
+				Name:     "imagetag",
+				Metadata: doop.MetadataForConstraint{Severity: "error"},
+			}},
+		}}}
+		// violations are counted across all groups of a constraint
+		for _, name := range podNames {
+			report.Templates[0].Constraints[0].ViolationGroups = append(report.Templates[0].Constraints[0].ViolationGroups, doop.ViolationGroup{
+				Pattern:   doop.Violation{Kind: "Pod", Namespace: name},
+				Instances: []doop.Violation{{Name: name + "-1"}, {Name: name + "-2"}},
+			})
+		}
+		return report
+	}
+
+	updateViolationMetrics("cluster1", makeReport("foo", "bar"))
+	updateViolationMetrics("cluster2", makeReport("foo"))
+	assert.Equal(t, getMetrics(), []string{
+		`doop_analyzer_violation_groups{cluster="cluster1",constraint_name="imagetag",severity="error",template_kind="GkImageTag"} 2`,
+		`doop_analyzer_violation_groups{cluster="cluster2",constraint_name="imagetag",severity="error",template_kind="GkImageTag"} 1`,
+		`doop_analyzer_violations{cluster="cluster1",constraint_name="imagetag",severity="error",template_kind="GkImageTag"} 4`,
+		`doop_analyzer_violations{cluster="cluster2",constraint_name="imagetag",severity="error",template_kind="GkImageTag"} 2`,
+	})
+
+	// when the violations in a cluster disappear, its series are removed, and other clusters are not affected
+	updateViolationMetrics("cluster1", doop.Report{})
+	assert.Equal(t, getMetrics(), []string{
+		`doop_analyzer_violation_groups{cluster="cluster2",constraint_name="imagetag",severity="error",template_kind="GkImageTag"} 1`,
+		`doop_analyzer_violations{cluster="cluster2",constraint_name="imagetag",severity="error",template_kind="GkImageTag"} 2`,
+	})
+}
+
+func TestSendReportWithBrokenKubeconfig(t *testing.T) {
+	// a broken Kubernetes client configuration only fails the report for this target, and is retried with the next report
+	target := analyzerTarget{Config: Configuration{
+		Kubernetes: KubernetesConfiguration{KubeconfigPath: filepath.Join(t.TempDir(), "does-not-exist")},
+	}}
+	for range 2 {
+		err := sendReport(t.Context(), &target)
+		if err == nil || !strings.Contains(err.Error(), "cannot assemble Kubernetes client config") {
+			t.Errorf("expected error about Kubernetes client config, but got %v", err)
+		}
+		assert.Equal(t, target.ClientSet, nil)
+	}
+}
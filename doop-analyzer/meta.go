@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sapcc/go-api-declarations/bininfo"
+	"github.com/sapcc/go-bits/logg"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// GatekeeperConfiguration appears in type Configuration.
+type GatekeeperConfiguration struct {
+	Namespace      string `json:"namespace"`
+	DeploymentName string `json:"deployment_name"`
+}
+
+// GetGatekeeperVersion returns the image tag of the Gatekeeper controller manager.
+func (cs ClientSet) GetGatekeeperVersion(ctx context.Context, cfg GatekeeperConfiguration) (string, error) {
+	deployment, err := cs.core.AppsV1().Deployments(cfg.Namespace).Get(ctx, cfg.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("cannot get Deployment %s/%s: %w", cfg.Namespace, cfg.DeploymentName, err)
+	}
+
+	// prefer the container that Gatekeeper's own manifests call "manager"
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return "", fmt.Errorf("Deployment %s/%s has no containers", cfg.Namespace, cfg.DeploymentName)
+	}
+	image := containers[0].Image
+	for _, c := range containers {
+		if c.Name == "manager" {
+			image = c.Image
+		}
+	}
+	return imageTag(image), nil
+}
+
+// GetKubernetesVersion returns the version of the Kubernetes API server.
+func (cs ClientSet) GetKubernetesVersion(ctx context.Context) (string, error) {
+	info, err := cs.core.Discovery().ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("cannot get Kubernetes server version: %w", err)
+	}
+	return info.GitVersion, nil
+}
+
+// imageTag extracts the tag from an image reference like "registry:5000/gatekeeper:v3.17.1@sha256:...".
+// If the image reference does not contain a tag, the empty string is returned.
+func imageTag(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	lastSlashIdx := strings.LastIndex(image, "/")
+	lastColonIdx := strings.LastIndex(image, ":")
+	if lastColonIdx <= lastSlashIdx {
+		return ""
+	}
+	return image[lastColonIdx+1:]
+}
+
+// gatherReportMeta builds the ReportMeta for a report whose collection started at the given time.
+// Since version detection is not essential, it is best-effort: errors are logged, but do not fail the report.
+func gatherReportMeta(ctx context.Context, cfg Configuration, cs ClientSetInterface, start time.Time) *doop.ReportMeta {
+	meta := doop.ReportMeta{
+		SchemaVersion:   doop.CurrentSchemaVersion,
+		AnalyzerVersion: bininfo.VersionOr("rolling"),
+		ConfigHash:      cfg.hash,
+		CollectedAt:     start.UTC().Format(time.RFC3339),
+	}
+
+	var err error
+	meta.GatekeeperVersion, err = cs.GetGatekeeperVersion(ctx, cfg.Gatekeeper)
+	if err != nil {
+		logg.Error("could not detect Gatekeeper version: %s", err.Error())
+	}
+	meta.KubernetesVersion, err = cs.GetKubernetesVersion(ctx)
+	if err != nil {
+		logg.Error("could not detect Kubernetes version: %s", err.Error())
+	}
+
+	meta.Durations.GatherSecs = time.Since(start).Seconds()
+	return &meta
+}
@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// MutatorsConfiguration appears in type Configuration.
+type MutatorsConfiguration struct {
+	Enabled bool `json:"enabled"`
+}
+
+// mutatorResources lists all resources in the `mutations.gatekeeper.sh` API group that we know about.
+var mutatorResources = []schema.GroupVersionResource{
+	{Group: "mutations.gatekeeper.sh", Version: "v1", Resource: "assign"},
+	{Group: "mutations.gatekeeper.sh", Version: "v1", Resource: "assignmetadata"},
+	{Group: "mutations.gatekeeper.sh", Version: "v1", Resource: "modifyset"},
+	{Group: "mutations.gatekeeper.sh", Version: "v1alpha1", Resource: "assignimage"},
+}
+
+// Mutator is the unpacked form of any object in the `mutations.gatekeeper.sh` API group.
+type Mutator struct {
+	Kind     string            `json:"kind"`
+	Metadata metav1.ObjectMeta `json:"metadata"`
+	Status   struct {
+		ByPod []struct {
+			ID       string `json:"id"`
+			Enforced bool   `json:"enforced"`
+			Errors   []struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"byPod"`
+	} `json:"status"`
+}
+
+// ListMutators lists all mutators of all kinds.
+// If collecting mutators is not enabled in the configuration, nothing is returned.
+func (cs ClientSet) ListMutators(ctx context.Context) ([]Mutator, error) {
+	if cs.mutations == nil {
+		return nil, nil
+	}
+
+	var result []Mutator
+	for _, gvr := range mutatorResources {
+		list, err := cs.mutations.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			// the respective CRD does not exist (e.g. because AssignImage is not supported by this Gatekeeper version)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot list %s: %w", gvr.GroupResource(), err)
+		}
+
+		// convert items from unstructured.Unstructured to Mutator through a JSON roundtrip
+		for _, item := range list.Items {
+			jsonBytes, err := json.Marshal(item.Object)
+			if err != nil {
+				return nil, fmt.Errorf("cannot encode mutator as JSON: %w", err)
+			}
+			var m Mutator
+			err = json.Unmarshal(jsonBytes, &m)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decode mutator from JSON: %w", err)
+			}
+			result = append(result, m)
+		}
+	}
+	return result, nil
+}
+
+func gatherReportForMutator(m Mutator) doop.ReportForMutator {
+	result := doop.ReportForMutator{
+		Kind: m.Kind,
+		Name: m.Metadata.Name,
+		Pods: make([]doop.MutatorPodStatus, len(m.Status.ByPod)),
+	}
+	for idx, ps := range m.Status.ByPod {
+		result.Pods[idx] = doop.MutatorPodStatus{
+			ID:       ps.ID,
+			Enforced: ps.Enforced,
+		}
+		for _, e := range ps.Errors {
+			result.Pods[idx].Errors = append(result.Pods[idx].Errors, doop.MutatorError{
+				Type:    e.Type,
+				Message: e.Message,
+			})
+		}
+	}
+	return result
+}
@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sapcc/gatekeeper-addons/internal/doop"
 )
@@ -73,8 +74,13 @@ func (r Rule) execute(data map[string]string) {
 	}
 }
 
-// ProcessReport applies the configured ProcessingRules and MergingRules to this report.
+// ProcessReport applies the configured redaction, ProcessingRules and MergingRules to this report.
 func ProcessReport(r *doop.Report, cfg Configuration) {
+	start := time.Now()
+	// redaction needs to happen first, so that sensitive data cannot be copied
+	// elsewhere by processing rules, and so that merging rules see the redaction markers
+	r.Redactions = RedactReport(r, cfg.Redaction)
+
 	for _, rt := range r.Templates {
 		for idx := range rt.Constraints {
 			// In this loop, we need to address via index instead of copy-by-value
@@ -87,6 +93,15 @@ func ProcessReport(r *doop.Report, cfg Configuration) {
 			runtime.Gosched()
 		}
 	}
+	for _, rt := range r.Admissions {
+		for idx := range rt.Constraints {
+			processAdmissionReportForConstraint(&rt.Constraints[idx], cfg)
+		}
+	}
+
+	if r.Meta != nil {
+		r.Meta.Durations.ProcessSecs = time.Since(start).Seconds()
+	}
 }
 
 func processReportForConstraint(rc *doop.ReportForConstraint, cfg Configuration) {
@@ -121,3 +136,36 @@ VIOLATION:
 
 	rc.Violations = nil
 }
+
+func processAdmissionReportForConstraint(rc *doop.AdmissionReportForConstraint, cfg Configuration) {
+	// Admission events are not grouped by merging rules since each event is already an aggregate.
+	// But processing rules are applied in the same way as for violations, which may
+	// make some events identical to each other; those need to be merged.
+	events := rc.Events
+	rc.Events = nil
+
+EVENT:
+	for _, e := range events {
+		v := doop.Violation{
+			Kind:           e.Kind,
+			Name:           e.Name,
+			Namespace:      e.Namespace,
+			Message:        e.Message,
+			ObjectIdentity: e.ObjectIdentity,
+		}
+		ExecuteRulesOnViolation(cfg.ProcessingRules, &v)
+		e.Kind = v.Kind
+		e.Name = v.Name
+		e.Namespace = v.Namespace
+		e.Message = v.Message
+		e.ObjectIdentity = v.ObjectIdentity
+
+		for idx, other := range rc.Events {
+			if e.IsSameAs(other) {
+				rc.Events[idx].MergeFrom(e)
+				continue EVENT
+			}
+		}
+		rc.Events = append(rc.Events, e)
+	}
+}
@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sapcc/go-bits/errext"
+	"github.com/sapcc/go-bits/regexpext"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// RedactionConfiguration appears in type Configuration.
+type RedactionConfiguration struct {
+	// If true, the detectors from builtinRedactionDetectors are applied to all attributes.
+	BuiltinDetectors bool            `json:"builtin_detectors"`
+	Rules            []RedactionRule `json:"rules"`
+}
+
+// RedactionRule appears in type RedactionConfiguration.
+type RedactionRule struct {
+	// Appears in the redaction marker and in the redaction counts.
+	Name string `json:"name"`
+	// One of "kind", "name", "namespace", "message" or "object_identity.$KEY". Defaults to "message".
+	Attribute string                `json:"attribute"`
+	Pattern   regexpext.PlainRegexp `json:"pattern"`
+}
+
+// builtinRedactionDetectors match common token formats. They are kept deliberately
+// specific to avoid redacting harmless identifiers like UUIDs or image digests.
+var builtinRedactionDetectors = []struct {
+	Name    string
+	Pattern *regexp.Regexp
+}{
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"bearer-token", regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{20,}=*`)},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})\b`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]+`)},
+	{"private-key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----(?s:.*?)-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+}
+
+func (rc RedactionConfiguration) validate() (errs errext.ErrorSet) {
+	for idx, rule := range rc.Rules {
+		path := fmt.Sprintf("redaction.rules[%d]", idx)
+		if rule.Name == "" {
+			errs.Addf("missing required configuration value: %s.name", path)
+		}
+		switch {
+		case rule.Attribute == "", rule.Attribute == "kind", rule.Attribute == "name", rule.Attribute == "namespace", rule.Attribute == "message":
+		case strings.HasPrefix(rule.Attribute, "object_identity.") && rule.Attribute != "object_identity.":
+		default:
+			errs.Addf("invalid value for %s.attribute: %q", path, rule.Attribute)
+		}
+		if rule.Pattern == "" {
+			errs.Addf("missing required configuration value: %s.pattern (rule %q)", path, rule.Name)
+		}
+	}
+	return
+}
+
+// RedactReport replaces sensitive data in all violations and admission events
+// with markers like "<redacted:aws-access-key>". Since the marker does not
+// depend on the redacted value, violations that only differ in redacted values
+// will be grouped together by the merging rules.
+//
+// The result counts how many spans were redacted by each rule or detector.
+func RedactReport(r *doop.Report, cfg RedactionConfiguration) map[string]int {
+	counts := make(map[string]int)
+	if !cfg.BuiltinDetectors && len(cfg.Rules) == 0 {
+		return counts
+	}
+
+	for _, rt := range r.Templates {
+		for _, rc := range rt.Constraints {
+			for idx := range rc.Violations {
+				cfg.redactViolation(&rc.Violations[idx], counts)
+			}
+		}
+	}
+	for _, rt := range r.Admissions {
+		for _, rc := range rt.Constraints {
+			for idx, e := range rc.Events {
+				v := doop.Violation{
+					Kind:           e.Kind,
+					Name:           e.Name,
+					Namespace:      e.Namespace,
+					Message:        e.Message,
+					ObjectIdentity: e.ObjectIdentity,
+				}
+				cfg.redactViolation(&v, counts)
+				rc.Events[idx].Kind = v.Kind
+				rc.Events[idx].Name = v.Name
+				rc.Events[idx].Namespace = v.Namespace
+				rc.Events[idx].Message = v.Message
+			}
+		}
+	}
+	return counts
+}
+
+func (rc RedactionConfiguration) redactViolation(v *doop.Violation, counts map[string]int) {
+	attributes := map[string]*string{
+		"kind":      &v.Kind,
+		"name":      &v.Name,
+		"namespace": &v.Namespace,
+		"message":   &v.Message,
+	}
+	// map values are not addressable, so object identity values are redacted in copies and written back at the end
+	oidValues := make(map[string]*string, len(v.ObjectIdentity))
+	for key, value := range v.ObjectIdentity {
+		oidValues[key] = &value
+		attributes["object_identity."+key] = &value
+	}
+
+	for _, rule := range rc.Rules {
+		attribute := rule.Attribute
+		if attribute == "" {
+			attribute = "message"
+		}
+		value, ok := attributes[attribute]
+		if !ok {
+			continue
+		}
+		rx, err := rule.Pattern.Regexp()
+		if err != nil {
+			// cannot happen because the regex was already validated when parsing the config;
+			// but if it does, redacting the entire value is the safe choice
+			*value = redactionMarker(rule.Name)
+			counts[rule.Name]++
+			continue
+		}
+		*value = redactAll(*value, rx, rule.Name, counts)
+	}
+
+	if rc.BuiltinDetectors {
+		for _, detector := range builtinRedactionDetectors {
+			for _, value := range attributes {
+				*value = redactAll(*value, detector.Pattern, detector.Name, counts)
+			}
+		}
+	}
+
+	for key, value := range oidValues {
+		v.ObjectIdentity[key] = *value
+	}
+}
+
+func redactAll(value string, rx *regexp.Regexp, name string, counts map[string]int) string {
+	return rx.ReplaceAllStringFunc(value, func(string) string {
+		counts[name]++
+		return redactionMarker(name)
+	})
+}
+
+func redactionMarker(name string) string {
+	return fmt.Sprintf("<redacted:%s>", name)
+}
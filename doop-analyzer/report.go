@@ -6,54 +6,136 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"regexp"
+	"slices"
+	"time"
 
 	"github.com/sapcc/gatekeeper-addons/internal/doop"
 )
 
 // GatherReport reads all constraint templates and configs and compiles a report.
+//
+// If an audit export path is configured, violations are taken from the latest
+// audit run exported by Gatekeeper instead of from the constraint status.
+// If a scope is configured, only violations and admission events within that scope are reported.
 func GatherReport(ctx context.Context, cfg Configuration, cs ClientSetInterface) (doop.Report, error) {
-	r := doop.Report{ClusterIdentity: cfg.ClusterIdentity}
+	start := time.Now()
+	r := doop.Report{ClusterIdentity: cfg.ClusterIdentity, Scope: cfg.Scope.Describe()}
+
+	scope, err := newScopeMatcher(ctx, cfg.Scope, cs)
+	if err != nil {
+		return doop.Report{}, err
+	}
+
+	var auditRun *AuditRun
+	if cfg.AuditExport.Path != "" {
+		run, err := ReadLatestAuditRun(cfg.AuditExport.Path)
+		if err != nil {
+			return doop.Report{}, err
+		}
+		auditRun = &run
+	}
 
 	templates, err := cs.ListConstraintTemplates(ctx)
 	if err != nil {
 		return doop.Report{}, err
 	}
+	metadataByConstraint := make(map[constraintRef]doop.MetadataForConstraint)
 	for _, t := range templates {
-		rt, err := gatherReportForTemplate(ctx, cs, t)
+		rt, dt, err := gatherReportForTemplate(ctx, cfg, cs, t, auditRun, scope, metadataByConstraint)
 		if err != nil {
 			return doop.Report{}, err
 		}
 		if len(rt.Constraints) > 0 {
 			r.Templates = append(r.Templates, rt)
 		}
+		r.Definitions = append(r.Definitions, dt)
 	}
 
+	records, err := cs.ListAdmissionEvents(ctx)
+	if err != nil {
+		return doop.Report{}, err
+	}
+	r.Admissions = gatherAdmissionReport(records, scope, metadataByConstraint)
+
+	mutators, err := cs.ListMutators(ctx)
+	if err != nil {
+		return doop.Report{}, err
+	}
+	for _, m := range mutators {
+		r.Mutators = append(r.Mutators, gatherReportForMutator(m))
+	}
+
+	r.Meta = gatherReportMeta(ctx, cfg, cs, start)
 	return r, nil
 }
 
-func gatherReportForTemplate(ctx context.Context, cs ClientSetInterface, t ConstraintTemplate) (doop.ReportForTemplate, error) {
+func gatherReportForTemplate(ctx context.Context, cfg Configuration, cs ClientSetInterface, t ConstraintTemplate, auditRun *AuditRun, scope scopeMatcher, metadataByConstraint map[constraintRef]doop.MetadataForConstraint) (doop.ReportForTemplate, doop.DefinitionForTemplate, error) {
 	rt := doop.ReportForTemplate{
 		Kind: t.Spec.CRD.Spec.Names.Kind,
 	}
+	dt, err := gatherDefinitionForTemplate(cfg, t)
+	if err != nil {
+		return doop.ReportForTemplate{}, doop.DefinitionForTemplate{}, err
+	}
 
 	configs, err := cs.ListConstraints(ctx, t)
 	if err != nil {
-		return doop.ReportForTemplate{}, err
+		return doop.ReportForTemplate{}, doop.DefinitionForTemplate{}, err
 	}
 	for _, c := range configs {
-		rc := gatherReportForConstraint(c)
+		if auditRun != nil {
+			c = auditRun.ApplyTo(c)
+		}
+		rc := gatherReportForConstraint(c, scope)
+		metadataByConstraint[constraintRef{Kind: rt.Kind, Name: rc.Name}] = rc.Metadata
 		if len(rc.Violations) > 0 {
 			rt.Constraints = append(rt.Constraints, rc)
 		}
+		dc, err := gatherDefinitionForConstraint(cfg, c)
+		if err != nil {
+			return doop.ReportForTemplate{}, doop.DefinitionForTemplate{}, err
+		}
+		dt.Constraints = append(dt.Constraints, dc)
 	}
 
-	return rt, nil
+	return rt, dt, nil
+}
+
+func gatherDefinitionForTemplate(cfg Configuration, t ConstraintTemplate) (doop.DefinitionForTemplate, error) {
+	hash, err := doop.HashSpec(t.RawSpec)
+	if err != nil {
+		return doop.DefinitionForTemplate{}, fmt.Errorf("in ConstraintTemplate %s: %w", t.Metadata.Name, err)
+	}
+	dt := doop.DefinitionForTemplate{
+		Kind: t.Spec.CRD.Spec.Names.Kind,
+		Hash: hash,
+	}
+	if cfg.Definitions.IncludeSpecs {
+		dt.Spec = t.RawSpec
+	}
+	return dt, nil
+}
+
+func gatherDefinitionForConstraint(cfg Configuration, c Constraint) (doop.DefinitionForConstraint, error) {
+	hash, err := doop.HashSpec(c.Spec)
+	if err != nil {
+		return doop.DefinitionForConstraint{}, fmt.Errorf("in %s %s: %w", c.Kind, c.Metadata.Name, err)
+	}
+	dc := doop.DefinitionForConstraint{
+		Name: c.Metadata.Name,
+		Hash: hash,
+	}
+	if cfg.Definitions.IncludeSpecs {
+		dc.Spec = c.Spec
+	}
+	return dc, nil
 }
 
 var objectIdentityRx = regexp.MustCompile(`^(\{.*?\})\s*>>\s*(.*)$`)
 
-func gatherReportForConstraint(c Constraint) doop.ReportForConstraint {
+func gatherReportForConstraint(c Constraint, scope scopeMatcher) doop.ReportForConstraint {
 	cm := c.Metadata
 	rc := doop.ReportForConstraint{
 		Name: cm.Name,
@@ -66,20 +148,14 @@ func gatherReportForConstraint(c Constraint) doop.ReportForConstraint {
 		},
 	}
 
+	if !scope.MatchConstraint(cm.Name) {
+		return rc
+	}
 	for _, v := range c.Status.Violations {
-		// extract the object identity prefix from the violation message, if any
-		processedMessage := v.Message
-		var objectIdentity map[string]string
-		match := objectIdentityRx.FindStringSubmatch(processedMessage)
-		if match != nil {
-			err := json.Unmarshal([]byte(match[1]), &objectIdentity)
-			if err == nil {
-				processedMessage = match[2]
-			} else {
-				objectIdentity = nil
-			}
+		if !scope.MatchObject(v.Kind, v.Namespace) {
+			continue
 		}
-
+		processedMessage, objectIdentity := splitObjectIdentity(v.Message)
 		rc.Violations = append(rc.Violations, doop.Violation{
 			Kind:           v.Kind,
 			Name:           v.Name,
@@ -91,3 +167,54 @@ func gatherReportForConstraint(c Constraint) doop.ReportForConstraint {
 
 	return rc
 }
+
+func gatherAdmissionReport(records []AdmissionEventRecord, scope scopeMatcher, metadataByConstraint map[constraintRef]doop.MetadataForConstraint) []doop.AdmissionReportForTemplate {
+	var result []doop.AdmissionReportForTemplate
+	for _, record := range records {
+		if !scope.MatchConstraint(record.ConstraintName) || !scope.MatchObject(record.Event.Kind, record.Event.Namespace) {
+			continue
+		}
+
+		// find or create the respective AdmissionReportForTemplate
+		tidx := slices.IndexFunc(result, func(rt doop.AdmissionReportForTemplate) bool {
+			return rt.Kind == record.ConstraintKind
+		})
+		if tidx == -1 {
+			tidx = len(result)
+			result = append(result, doop.AdmissionReportForTemplate{Kind: record.ConstraintKind})
+		}
+		rt := &result[tidx]
+
+		// find or create the respective AdmissionReportForConstraint
+		cidx := slices.IndexFunc(rt.Constraints, func(rc doop.AdmissionReportForConstraint) bool {
+			return rc.Name == record.ConstraintName
+		})
+		if cidx == -1 {
+			// the audit timestamp is irrelevant for admission events
+			metadata := metadataByConstraint[constraintRef{Kind: record.ConstraintKind, Name: record.ConstraintName}]
+			metadata.AuditTimestamp = ""
+			cidx = len(rt.Constraints)
+			rt.Constraints = append(rt.Constraints, doop.AdmissionReportForConstraint{
+				Name:     record.ConstraintName,
+				Metadata: metadata,
+			})
+		}
+		rc := &rt.Constraints[cidx]
+		rc.Events = append(rc.Events, record.Event)
+	}
+	return result
+}
+
+// splitObjectIdentity extracts the object identity prefix from a violation message, if any.
+func splitObjectIdentity(message string) (string, map[string]string) {
+	match := objectIdentityRx.FindStringSubmatch(message)
+	if match == nil {
+		return message, nil
+	}
+	var objectIdentity map[string]string
+	err := json.Unmarshal([]byte(match[1]), &objectIdentity)
+	if err != nil {
+		return message, nil
+	}
+	return match[2], objectIdentity
+}
@@ -11,7 +11,12 @@ import (
 	"testing"
 
 	"github.com/sapcc/go-bits/must"
+	"github.com/sapcc/go-bits/regexpext"
+	"go.xyrillian.de/gg/assert"
 	"go.xyrillian.de/gg/jsonmatch"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
 )
 
 func TestGatherReport(t *testing.T) {
@@ -21,11 +26,18 @@ func TestGatherReport(t *testing.T) {
 	cfg := Configuration{
 		ClusterIdentity: map[string]string{"ci_key1": "ci_value1", "ci_key2": "ci_value2"},
 	}
+	cfg.Definitions.IncludeSpecs = true
 	report, err := GatherReport(t.Context(), cfg, mockClientSet{})
 	if err != nil {
 		t.Fatal(err.Error())
 	}
 
+	// the report metadata contains timestamps and durations, so it cannot be compared against the fixture
+	assert.Equal(t, report.Meta.SchemaVersion, doop.CurrentSchemaVersion)
+	assert.Equal(t, report.Meta.GatekeeperVersion, "v3.17.1")
+	assert.Equal(t, report.Meta.KubernetesVersion, "v1.31.4")
+	report.Meta = nil
+
 	reportBuf, err := json.Marshal(report)
 	if err != nil {
 		t.Fatal(err.Error())
@@ -50,6 +62,36 @@ func (mockClientSet) ListConstraints(ctx context.Context, tmpl ConstraintTemplat
 	return readItemListFromJSON[Constraint](path)
 }
 
+func (mockClientSet) ListAdmissionEvents(ctx context.Context) (result []AdmissionEventRecord, e error) {
+	events, err := readItemListFromJSON[corev1.Event]("fixtures/gatekeeper/events.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, ev := range events {
+		record, ok := parseAdmissionEvent(ev)
+		if ok {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+func (mockClientSet) ListMutators(ctx context.Context) ([]Mutator, error) {
+	return readItemListFromJSON[Mutator]("fixtures/gatekeeper/mutators.json")
+}
+
+func (mockClientSet) ListNamespaces(ctx context.Context) ([]corev1.Namespace, error) {
+	return readItemListFromJSON[corev1.Namespace]("fixtures/gatekeeper/namespaces.json")
+}
+
+func (mockClientSet) GetGatekeeperVersion(ctx context.Context, cfg GatekeeperConfiguration) (string, error) {
+	return "v3.17.1", nil
+}
+
+func (mockClientSet) GetKubernetesVersion(ctx context.Context) (string, error) {
+	return "v1.31.4", nil
+}
+
 func readItemListFromJSON[T any](path string) ([]T, error) {
 	buf, err := os.ReadFile(path)
 	if err != nil {
@@ -61,3 +103,109 @@ func readItemListFromJSON[T any](path string) ([]T, error) {
 	err = json.Unmarshal(buf, &result)
 	return result.Items, err
 }
+
+func TestGatherReportFromAuditExport(t *testing.T) {
+	// This test is like TestGatherReport, but the violations are taken from the
+	// Gatekeeper audit export in `fixtures/audit-export/` instead of from the
+	// constraint status. Only the latest completed audit run shall be considered.
+
+	cfg := Configuration{
+		ClusterIdentity: map[string]string{"ci_key1": "ci_value1", "ci_key2": "ci_value2"},
+	}
+	cfg.AuditExport.Path = "fixtures/audit-export"
+	report, err := GatherReport(t.Context(), cfg, mockClientSet{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	report.Meta = nil
+
+	reportBuf, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expectedBuf := must.ReturnT(os.ReadFile("fixtures/report-from-audit-export.json"))(t)
+	var expectedData jsonmatch.Object
+	must.SucceedT(t, json.Unmarshal(expectedBuf, &expectedData))
+	for _, diff := range expectedData.DiffAgainst(reportBuf) {
+		t.Error(diff.String())
+	}
+}
+
+func TestGatherReportWithScope(t *testing.T) {
+	// This test is like TestGatherReport, but only a subset of the violations is in scope.
+	cfg := Configuration{
+		ClusterIdentity: map[string]string{"ci_key1": "ci_value1", "ci_key2": "ci_value2"},
+		Scope: ScopeConfiguration{
+			Include: ScopeSelectorConfiguration{
+				Namespaces: []regexpext.BoundedRegexp{"kube-.*", "kubernikus", "vmware-system-csi"},
+			},
+			Exclude: ScopeSelectorConfiguration{
+				NamespaceLabels: "sandbox=true",
+				Kinds:           []string{"ConfigMap"},
+			},
+		},
+	}
+	report, err := GatherReport(t.Context(), cfg, mockClientSet{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// list violations and admission events as "$CONSTRAINT: $NAMESPACE/$NAME" to compare them more easily
+	var violations, admissions []string
+	for _, rt := range report.Templates {
+		for _, rc := range rt.Constraints {
+			for _, v := range rc.Violations {
+				violations = append(violations, fmt.Sprintf("%s: %s/%s", rc.Name, v.Namespace, v.Name))
+			}
+		}
+	}
+	for _, rt := range report.Admissions {
+		for _, rc := range rt.Constraints {
+			for _, e := range rc.Events {
+				admissions = append(admissions, fmt.Sprintf("%s: %s/%s", rc.Name, e.Namespace, e.Name))
+			}
+		}
+	}
+
+	// the violation in namespace "kubernikus" is excluded by its namespace labels
+	assert.Equal(t, violations, []string{
+		"outdatedimagebases: kube-monitoring/kube-monitoring-prometheus-node-exporter-8944q",
+		"outdatedimagebases: kube-monitoring/kube-monitoring-prometheus-node-exporter-l67vv",
+		"outdatedimagebases: kube-monitoring/kube-monitoring-prometheus-node-exporter-t49jm",
+		"outdatedimagebases: kube-monitoring/kube-monitoring-prometheus-node-exporter-fz2rg",
+		"ownerinfoonhelmreleases: vmware-system-csi/sh.helm.release.v1.vsphere-csi.v1",
+	})
+	assert.Equal(t, len(admissions), 3)
+
+	// excluding a constraint removes its violations, but not its definition
+	cfg.Scope.Exclude.Constraints = []string{"outdatedimagebases"}
+	report, err = GatherReport(t.Context(), cfg, mockClientSet{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, len(report.Templates), 1)
+	assert.Equal(t, report.Templates[0].Kind, "GkOwnerInfoOnHelmReleases")
+	assert.Equal(t, len(report.Admissions), 1)
+	assert.Equal(t, report.Admissions[0].Kind, "GkOwnerInfoOnHelmReleases")
+	assert.Equal(t, len(report.Definitions), 2)
+
+	// the active scope is recorded in the report
+	assert.Equal(t, *report.Scope, doop.Scope{
+		Include: doop.ScopeSelector{
+			Namespaces: []string{"kube-.*", "kubernikus", "vmware-system-csi"},
+		},
+		Exclude: doop.ScopeSelector{
+			NamespaceLabels: "sandbox=true",
+			Kinds:           []string{"ConfigMap"},
+			Constraints:     []string{"outdatedimagebases"},
+		},
+	})
+}
+
+func TestImageTag(t *testing.T) {
+	assert.Equal(t, imageTag("openpolicyagent/gatekeeper:v3.17.1"), "v3.17.1")
+	assert.Equal(t, imageTag("registry.example.com:5000/openpolicyagent/gatekeeper:v3.17.1"), "v3.17.1")
+	assert.Equal(t, imageTag("openpolicyagent/gatekeeper:v3.17.1@sha256:0123456789abcdef"), "v3.17.1")
+	assert.Equal(t, imageTag("registry.example.com:5000/openpolicyagent/gatekeeper"), "")
+}
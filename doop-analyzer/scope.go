@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/sapcc/go-bits/errext"
+	"github.com/sapcc/go-bits/regexpext"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// ScopeConfiguration appears in type Configuration.
+type ScopeConfiguration struct {
+	Include ScopeSelectorConfiguration `json:"include"`
+	Exclude ScopeSelectorConfiguration `json:"exclude"`
+}
+
+// ScopeSelectorConfiguration appears in type ScopeConfiguration.
+type ScopeSelectorConfiguration struct {
+	Namespaces      []regexpext.BoundedRegexp `json:"namespaces"`
+	NamespaceLabels string                    `json:"namespace_labels"`
+	Kinds           []string                  `json:"kinds"`
+	Constraints     []string                  `json:"constraints"`
+}
+
+// IsEmpty returns whether this selector does not restrict anything.
+func (s ScopeSelectorConfiguration) IsEmpty() bool {
+	return len(s.Namespaces) == 0 && s.NamespaceLabels == "" && len(s.Kinds) == 0 && len(s.Constraints) == 0
+}
+
+func (sc ScopeConfiguration) validate() (errs errext.ErrorSet) {
+	if sc.Include.NamespaceLabels != "" {
+		_, err := labels.Parse(sc.Include.NamespaceLabels)
+		if err != nil {
+			errs.Addf("invalid value for scope.include.namespace_labels: %s", err.Error())
+		}
+	}
+	if sc.Exclude.NamespaceLabels != "" {
+		_, err := labels.Parse(sc.Exclude.NamespaceLabels)
+		if err != nil {
+			errs.Addf("invalid value for scope.exclude.namespace_labels: %s", err.Error())
+		}
+	}
+	return
+}
+
+// Describe returns the representation of this scope for the report, or nil if no scoping is configured.
+func (sc ScopeConfiguration) Describe() *doop.Scope {
+	if sc.Include.IsEmpty() && sc.Exclude.IsEmpty() {
+		return nil
+	}
+	describe := func(s ScopeSelectorConfiguration) doop.ScopeSelector {
+		result := doop.ScopeSelector{
+			NamespaceLabels: s.NamespaceLabels,
+			Kinds:           s.Kinds,
+			Constraints:     s.Constraints,
+		}
+		for _, rx := range s.Namespaces {
+			result.Namespaces = append(result.Namespaces, string(rx))
+		}
+		return result
+	}
+	return &doop.Scope{
+		Include: describe(sc.Include),
+		Exclude: describe(sc.Exclude),
+	}
+}
+
+// scopeMatcher decides whether constraints and violations are in scope.
+// It is built from a ScopeConfiguration by newScopeMatcher().
+type scopeMatcher struct {
+	cfg ScopeConfiguration
+	// only filled if a namespace label selector is configured
+	includeLabels   labels.Selector
+	excludeLabels   labels.Selector
+	namespaceLabels map[string]labels.Set
+}
+
+func newScopeMatcher(ctx context.Context, cfg ScopeConfiguration, cs ClientSetInterface) (scopeMatcher, error) {
+	m := scopeMatcher{cfg: cfg}
+	var err error
+	if cfg.Include.NamespaceLabels != "" {
+		m.includeLabels, err = labels.Parse(cfg.Include.NamespaceLabels)
+		if err != nil {
+			return scopeMatcher{}, fmt.Errorf("invalid value for scope.include.namespace_labels: %w", err)
+		}
+	}
+	if cfg.Exclude.NamespaceLabels != "" {
+		m.excludeLabels, err = labels.Parse(cfg.Exclude.NamespaceLabels)
+		if err != nil {
+			return scopeMatcher{}, fmt.Errorf("invalid value for scope.exclude.namespace_labels: %w", err)
+		}
+	}
+
+	// namespace labels only need to be fetched if any selector refers to them
+	if m.includeLabels != nil || m.excludeLabels != nil {
+		namespaces, err := cs.ListNamespaces(ctx)
+		if err != nil {
+			return scopeMatcher{}, err
+		}
+		m.namespaceLabels = make(map[string]labels.Set, len(namespaces))
+		for _, ns := range namespaces {
+			m.namespaceLabels[ns.Name] = labels.Set(ns.Labels)
+		}
+	}
+	return m, nil
+}
+
+// MatchConstraint checks whether violations of the given constraint shall be reported.
+func (m scopeMatcher) MatchConstraint(name string) bool {
+	if len(m.cfg.Include.Constraints) > 0 && !slices.Contains(m.cfg.Include.Constraints, name) {
+		return false
+	}
+	return !slices.Contains(m.cfg.Exclude.Constraints, name)
+}
+
+// MatchObject checks whether violations concerning the given object shall be reported.
+// Cluster-scoped objects are treated like objects in a namespace with an empty name and no labels.
+func (m scopeMatcher) MatchObject(kind, namespace string) bool {
+	inc := m.cfg.Include
+	if len(inc.Kinds) > 0 && !slices.Contains(inc.Kinds, kind) {
+		return false
+	}
+	if len(inc.Namespaces) > 0 && !slices.ContainsFunc(inc.Namespaces, matchesNamespace(namespace)) {
+		return false
+	}
+	if m.includeLabels != nil && !m.includeLabels.Matches(m.namespaceLabels[namespace]) {
+		return false
+	}
+
+	exc := m.cfg.Exclude
+	if slices.Contains(exc.Kinds, kind) {
+		return false
+	}
+	if slices.ContainsFunc(exc.Namespaces, matchesNamespace(namespace)) {
+		return false
+	}
+	if m.excludeLabels != nil && m.excludeLabels.Matches(m.namespaceLabels[namespace]) {
+		return false
+	}
+	return true
+}
+
+func matchesNamespace(namespace string) func(regexpext.BoundedRegexp) bool {
+	return func(rx regexpext.BoundedRegexp) bool {
+		return rx.MatchString(namespace)
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gophercloud/gophercloud/v2/openstack"
 	"github.com/sapcc/go-bits/gophercloudext"
@@ -22,22 +25,31 @@ import (
 // SwiftConfiguration appears in type Configuration. It also holds the methods
 // and state for talking to Swift.
 type SwiftConfiguration struct {
-	ServiceType   string `json:"service_type"`
-	ContainerName string `json:"container_name"`
-	ObjectName    string `json:"object_name"`
+	ServiceType   string                    `json:"service_type"`
+	ContainerName string                    `json:"container_name"`
+	ObjectName    string                    `json:"object_name"`
+	History       SwiftHistoryConfiguration `json:"history"`
 	// filled by Connect()
-	Object *schwift.Object `json:"-"`
+	Container *schwift.Container `json:"-"`
+}
+
+// SwiftHistoryConfiguration appears in type SwiftConfiguration.
+type SwiftHistoryConfiguration struct {
+	// How often history snapshots are written. If zero, no history snapshots are written.
+	Interval Duration `json:"interval"`
+	// How long history snapshots are kept before Swift deletes them.
+	Retention Duration `json:"retention"`
 }
 
 // Connect initializes the Swift client.
+//
+// Since the object name may differ between targets, it is not checked here.
+// Use CheckObjectName() on each target's configuration instead.
 func (s *SwiftConfiguration) Connect(ctx context.Context) error {
 	// check provided configuration
 	if s.ContainerName == "" {
 		return errors.New("missing required configuration value: swift.container_name")
 	}
-	if s.ObjectName == "" {
-		return errors.New("missing required configuration value: swift.object_name")
-	}
 
 	// connect to OpenStack
 	provider, eo, err := gophercloudext.NewProviderClient(ctx, nil)
@@ -57,7 +69,21 @@ func (s *SwiftConfiguration) Connect(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("cannot initialize Swift container: %w", err)
 	}
-	s.Object = swiftContainer.Object(s.ObjectName)
+	s.Container = swiftContainer
+	return nil
+}
+
+// CheckObjectName returns an error if no valid object name is configured.
+func (s SwiftConfiguration) CheckObjectName() error {
+	if s.ObjectName == "" {
+		return errors.New("missing required configuration value: swift.object_name (or targets[].object_name)")
+	}
+	if strings.HasPrefix(s.ObjectName, doop.HistoryObjectPrefix) {
+		return fmt.Errorf("invalid value for swift.object_name (or targets[].object_name): %q (the prefix %q is reserved for history snapshots)", s.ObjectName, doop.HistoryObjectPrefix)
+	}
+	if strings.HasPrefix(s.ObjectName, doop.APIStateObjectPrefix) {
+		return fmt.Errorf("invalid value for swift.object_name (or targets[].object_name): %q (the prefix %q is reserved for doop-api)", s.ObjectName, doop.APIStateObjectPrefix)
+	}
 	return nil
 }
 
@@ -68,9 +94,27 @@ func (s *SwiftConfiguration) SendReport(ctx context.Context, report doop.Report)
 		return fmt.Errorf("cannot encode report as JSON: %w", err)
 	}
 
-	err = s.Object.Upload(ctx, bytes.NewReader(buf), nil, nil)
+	err = s.Container.Object(s.ObjectName).Upload(ctx, bytes.NewReader(buf), nil, nil)
 	if err != nil {
 		return fmt.Errorf("cannot upload report to Swift: %w", err)
 	}
 	return nil
 }
+
+// SendHistorySnapshot uploads a processed report to Swift as a history snapshot for the given time.
+// Swift will delete the snapshot automatically once the configured retention period has passed.
+func (s *SwiftConfiguration) SendHistorySnapshot(ctx context.Context, report doop.Report, t time.Time) error {
+	buf, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("cannot encode report as JSON: %w", err)
+	}
+
+	hdr := schwift.NewObjectHeaders()
+	hdr.Set("X-Delete-After", strconv.FormatInt(int64(time.Duration(s.History.Retention).Seconds()), 10))
+	objectName := doop.HistoryObjectName(s.ObjectName, t)
+	err = s.Container.Object(objectName).Upload(ctx, bytes.NewReader(buf), nil, hdr.ToOpts())
+	if err != nil {
+		return fmt.Errorf("cannot upload history snapshot to Swift: %w", err)
+	}
+	return nil
+}
@@ -5,71 +5,180 @@ package main
 
 import (
 	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/sapcc/gatekeeper-addons/internal/doop"
 )
 
 // AggregateReports assembles a set of individual reports into an AggregatedReport.
 func AggregateReports(reports map[string]doop.Report, f FilterSet) doop.AggregatedReport {
-	target := doop.AggregatedReport{
-		ClusterIdentities: make(map[string]map[string]string),
+	a := aggregator{
+		Target: doop.AggregatedReport{
+			ClusterIdentities: make(map[string]map[string]string),
+		},
+		Filter:                   f,
+		templateIndex:            make(map[string]int),
+		constraintIndex:          make(map[constraintKey]int),
+		violationGroupIndex:      make(map[violationGroupKey]int),
+		admissionTemplateIndex:   make(map[string]int),
+		admissionConstraintIndex: make(map[constraintKey]int),
 	}
 
 	for clusterName, clusterReport := range reports {
-		visitClusterReport(&target, clusterReport, clusterName, f)
+		a.visitClusterReport(clusterReport, clusterName)
 	}
 
-	return target
+	return a.Target
 }
 
 // NOTE on the general implementation style: This uses a variant of the visitor pattern.
 // For each level that is processed, we have one function to avoid nesting loops too deeply within any one function.
 // To keep the output compact, we only add objects to lists if the lower levels of the call stack actually insert data.
 // We generally try to avoid heap allocations as much as possible for temporary objects because the nested loops make the inner functions very hot.
+//
+// To find the merge target for an object in constant time, the aggregator maintains an index for each level.
+// Since lists in the target may be reallocated while they grow, all indexes store list positions instead of pointers.
+// Objects are appended to the lists in the order in which they are first encountered, so the order of the
+// (yet unsorted) output is exactly the same as if the merge targets were found by linear search.
 
-func visitClusterReport(target *doop.AggregatedReport, clusterReport doop.Report, clusterName string, f FilterSet) {
-	if !f.MatchClusterIdentity(clusterReport.ClusterIdentity) {
+type aggregator struct {
+	Target doop.AggregatedReport
+	Filter FilterSet
+
+	// Key is template kind, value is position in Target.Templates.
+	templateIndex map[string]int
+	// Value is position in Target.Templates[key.TemplatePos].Constraints.
+	constraintIndex map[constraintKey]int
+	// Value is position in Target.Templates[key.TemplatePos].Constraints[key.ConstraintPos].ViolationGroups.
+	violationGroupIndex map[violationGroupKey]int
+	// Key is template kind, value is position in Target.Admissions.
+	admissionTemplateIndex map[string]int
+	// Value is position in Target.Admissions[key.TemplatePos].Constraints.
+	admissionConstraintIndex map[constraintKey]int
+}
+
+type constraintKey struct {
+	TemplatePos int
+	Name        string
+	Metadata    doop.MetadataForConstraint
+}
+
+type violationGroupKey struct {
+	TemplatePos   int
+	ConstraintPos int
+	Pattern       string // from patternKey()
+}
+
+// patternKey returns a string that is equal for two violations iff Violation.IsEqualTo() is true for them.
+func patternKey(v doop.Violation) string {
+	// collect and sort object identity keys without a heap allocation in the common case of few keys
+	var keysBuf [8]string
+	keys := keysBuf[:0]
+	size := len(v.Kind) + len(v.Name) + len(v.Namespace) + len(v.Message) + len(v.ClusterName)
+	for key, value := range v.ObjectIdentity {
+		keys = append(keys, key)
+		size += len(key) + len(value)
+	}
+	slices.Sort(keys)
+
+	// each field is prefixed with its length, so that field boundaries are unambiguous no matter what the fields contain
+	var sb strings.Builder
+	sb.Grow(size + 8*(5+2*len(keys)+1))
+	write := func(s string) {
+		sb.WriteString(strconv.Itoa(len(s)))
+		sb.WriteByte(':')
+		sb.WriteString(s)
+	}
+	write(v.Kind)
+	write(v.Name)
+	write(v.Namespace)
+	write(v.Message)
+	write(v.ClusterName)
+	sb.WriteString(strconv.Itoa(len(keys)))
+	for _, key := range keys {
+		write(key)
+		write(v.ObjectIdentity[key])
+	}
+	return sb.String()
+}
+
+// truncate is used to roll back the insertion of an object at the end of a list.
+// Since objects are only appended at the end, truncating to length 0 restores the list to its original nil state.
+func truncate[T any](list []T, length int) []T {
+	if length == 0 {
+		return nil
+	}
+	return list[:length]
+}
+
+func (a *aggregator) visitClusterReport(clusterReport doop.Report, clusterName string) {
+	target := &a.Target
+	if !a.Filter.MatchClusterIdentity(clusterReport.ClusterIdentity) {
+		return
+	}
+	if clusterReport.IsStale {
+		target.StaleClusters = append(target.StaleClusters, clusterName)
+	}
+	if !a.Filter.MatchStaleness(clusterReport.IsStale) {
 		return
 	}
 
 	target.ClusterIdentities[clusterName] = clusterReport.ClusterIdentity
+	if clusterReport.Scope != nil {
+		if target.Scopes == nil {
+			target.Scopes = make(map[string]doop.Scope)
+		}
+		target.Scopes[clusterName] = *clusterReport.Scope
+	}
+	if clusterReport.Meta != nil {
+		if target.Meta == nil {
+			target.Meta = make(map[string]doop.ReportMeta)
+		}
+		target.Meta[clusterName] = *clusterReport.Meta
+	}
 	for _, tr := range clusterReport.Templates {
-		visitTemplateReport(target, tr, f)
+		a.visitTemplateReport(tr)
+	}
+	for _, tr := range clusterReport.Admissions {
+		a.visitAdmissionReportForTemplate(tr)
 	}
 }
 
-func visitTemplateReport(target *doop.AggregatedReport, tr doop.ReportForTemplate, f FilterSet) {
-	if !f.MatchTemplateKind(tr.Kind) {
+func (a *aggregator) visitTemplateReport(tr doop.ReportForTemplate) {
+	if !a.Filter.MatchTemplateKind(tr.Kind) {
 		return
 	}
 
 	// try to merge into existing ReportForTemplate
-	for idx, candidate := range target.Templates {
-		if candidate.Kind == tr.Kind {
-			for _, cr := range tr.Constraints {
-				visitConstraintReport(&target.Templates[idx], cr, f)
-			}
-			return
+	pos, exists := a.templateIndex[tr.Kind]
+	if exists {
+		for _, cr := range tr.Constraints {
+			a.visitConstraintReport(pos, cr)
 		}
+		return
 	}
 
-	// otherwise try to start a new ReportForTemplate
-	newReport := doop.ReportForTemplate{
+	// otherwise try to start a new ReportForTemplate (and roll it back if it stays empty)
+	pos = len(a.Target.Templates)
+	a.Target.Templates = append(a.Target.Templates, doop.ReportForTemplate{
 		Kind: tr.Kind,
-	}
+	})
 	for _, cr := range tr.Constraints {
-		visitConstraintReport(&newReport, cr, f)
+		a.visitConstraintReport(pos, cr)
 	}
-	if len(newReport.Constraints) > 0 {
-		target.Templates = append(target.Templates, newReport)
+	if len(a.Target.Templates[pos].Constraints) > 0 {
+		a.templateIndex[tr.Kind] = pos
+	} else {
+		a.Target.Templates = truncate(a.Target.Templates, pos)
 	}
 }
 
-func visitConstraintReport(target *doop.ReportForTemplate, cr doop.ReportForConstraint, f FilterSet) {
-	if !f.MatchConstraintName(cr.Name) {
+func (a *aggregator) visitConstraintReport(templatePos int, cr doop.ReportForConstraint) {
+	if !a.Filter.MatchConstraintName(cr.Name) {
 		return
 	}
-	if !f.MatchSeverity(cr.Metadata.Severity) {
+	if !a.Filter.MatchSeverity(cr.Metadata.Severity) {
 		return
 	}
 
@@ -78,44 +187,133 @@ func visitConstraintReport(target *doop.ReportForTemplate, cr doop.ReportForCons
 	metadata.AuditTimestamp = ""
 
 	// try to merge into existing ReportForConstraint
-	for idx, candidate := range target.Constraints {
-		if candidate.Name == cr.Name && candidate.Metadata == metadata {
-			for _, vg := range cr.ViolationGroups {
-				visitViolationGroup(&target.Constraints[idx], vg, f)
-			}
-			return
+	key := constraintKey{templatePos, cr.Name, metadata}
+	pos, exists := a.constraintIndex[key]
+	if exists {
+		for _, vg := range cr.ViolationGroups {
+			a.visitViolationGroup(templatePos, pos, vg)
 		}
+		return
 	}
 
-	// otherwise try to start a new ReportForConstraint
-	newReport := doop.ReportForConstraint{
+	// otherwise try to start a new ReportForConstraint (and roll it back if it stays empty)
+	target := &a.Target.Templates[templatePos]
+	pos = len(target.Constraints)
+	target.Constraints = append(target.Constraints, doop.ReportForConstraint{
 		Name:     cr.Name,
 		Metadata: metadata,
-	}
+	})
 	for _, vg := range cr.ViolationGroups {
-		visitViolationGroup(&newReport, vg, f)
+		a.visitViolationGroup(templatePos, pos, vg)
 	}
-	if len(newReport.ViolationGroups) > 0 {
-		target.Constraints = append(target.Constraints, newReport)
+	if len(target.Constraints[pos].ViolationGroups) > 0 {
+		a.constraintIndex[key] = pos
+	} else {
+		target.Constraints = truncate(target.Constraints, pos)
 	}
 }
 
-func visitViolationGroup(target *doop.ReportForConstraint, vg doop.ViolationGroup, f FilterSet) {
+func (a *aggregator) visitViolationGroup(templatePos, constraintPos int, vg doop.ViolationGroup) {
+	f := a.Filter
 	if !f.MatchObjectIdentity(vg.Pattern.ObjectIdentity) {
 		return
 	}
 
-	// try to merge into existing ViolationGroup
-	for idx, candidate := range target.ViolationGroups {
-		if candidate.Pattern.IsEqualTo(vg.Pattern) {
-			target.ViolationGroups[idx].Instances = append(target.ViolationGroups[idx].Instances, vg.Instances...)
+	// filters on the violated object need to be checked for each instance because instances may deviate from the pattern
+	instances := vg.Instances
+	if f.HasObjectFilters() {
+		instances = nil
+		for _, v := range vg.Instances {
+			full := v.ExpandedFrom(vg.Pattern)
+			if f.MatchObject(full.Kind, full.Namespace, full.Name, full.Message) {
+				instances = append(instances, v)
+			}
+		}
+		if len(instances) == 0 {
 			return
 		}
 	}
 
+	// try to merge into existing ViolationGroup
+	target := &a.Target.Templates[templatePos].Constraints[constraintPos]
+	key := violationGroupKey{templatePos, constraintPos, patternKey(vg.Pattern)}
+	pos, exists := a.violationGroupIndex[key]
+	if exists {
+		target.ViolationGroups[pos].Instances = append(target.ViolationGroups[pos].Instances, instances...)
+		return
+	}
+
 	// otherwise start a new ViolationGroup
+	a.violationGroupIndex[key] = len(target.ViolationGroups)
 	target.ViolationGroups = append(target.ViolationGroups, doop.ViolationGroup{
 		Pattern:   vg.Pattern.Cloned(),
-		Instances: slices.Clone(vg.Instances),
+		Instances: slices.Clone(instances),
 	})
 }
+
+func (a *aggregator) visitAdmissionReportForTemplate(tr doop.AdmissionReportForTemplate) {
+	if !a.Filter.MatchTemplateKind(tr.Kind) {
+		return
+	}
+
+	// try to merge into existing AdmissionReportForTemplate
+	pos, exists := a.admissionTemplateIndex[tr.Kind]
+	if exists {
+		for _, cr := range tr.Constraints {
+			a.visitAdmissionReportForConstraint(pos, cr)
+		}
+		return
+	}
+
+	// otherwise try to start a new AdmissionReportForTemplate (and roll it back if it stays empty)
+	pos = len(a.Target.Admissions)
+	a.Target.Admissions = append(a.Target.Admissions, doop.AdmissionReportForTemplate{
+		Kind: tr.Kind,
+	})
+	for _, cr := range tr.Constraints {
+		a.visitAdmissionReportForConstraint(pos, cr)
+	}
+	if len(a.Target.Admissions[pos].Constraints) > 0 {
+		a.admissionTemplateIndex[tr.Kind] = pos
+	} else {
+		a.Target.Admissions = truncate(a.Target.Admissions, pos)
+	}
+}
+
+func (a *aggregator) visitAdmissionReportForConstraint(templatePos int, cr doop.AdmissionReportForConstraint) {
+	if !a.Filter.MatchConstraintName(cr.Name) {
+		return
+	}
+	if !a.Filter.MatchSeverity(cr.Metadata.Severity) {
+		return
+	}
+
+	// try to merge into existing AdmissionReportForConstraint
+	target := &a.Target.Admissions[templatePos]
+	key := constraintKey{templatePos, cr.Name, cr.Metadata}
+	pos, exists := a.admissionConstraintIndex[key]
+	if exists {
+		a.visitAdmissionEvents(&target.Constraints[pos], cr.Events)
+		return
+	}
+
+	// otherwise try to start a new AdmissionReportForConstraint
+	newReport := doop.AdmissionReportForConstraint{
+		Name:     cr.Name,
+		Metadata: cr.Metadata,
+	}
+	a.visitAdmissionEvents(&newReport, cr.Events)
+	if len(newReport.Events) > 0 {
+		a.admissionConstraintIndex[key] = len(target.Constraints)
+		target.Constraints = append(target.Constraints, newReport)
+	}
+}
+
+func (a *aggregator) visitAdmissionEvents(target *doop.AdmissionReportForConstraint, events []doop.AdmissionEvent) {
+	// since each event carries its ClusterName, events from different clusters are never merged
+	for _, e := range events {
+		if a.Filter.MatchObjectIdentity(e.ObjectIdentity) && a.Filter.MatchObject(e.Kind, e.Namespace, e.Name, e.Message) {
+			target.Events = append(target.Events, e)
+		}
+	}
+}
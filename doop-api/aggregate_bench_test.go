@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// makeSyntheticReports generates reports for the given number of clusters.
+// Each report has violations for the given number of templates, constraints per template and violation groups per constraint.
+// All reports use the same patterns, so that everything merges across clusters.
+func makeSyntheticReports(clusterCount, templateCount, constraintCount, groupCount int) map[string]doop.Report {
+	reports := make(map[string]doop.Report, clusterCount)
+	for clusterIdx := range clusterCount {
+		clusterName := fmt.Sprintf("cluster%03d", clusterIdx)
+		report := doop.Report{
+			ClusterIdentity: map[string]string{"region": fmt.Sprintf("region%d", clusterIdx%10)},
+		}
+		for templateIdx := range templateCount {
+			rt := doop.ReportForTemplate{Kind: fmt.Sprintf("GkTemplate%03d", templateIdx)}
+			for constraintIdx := range constraintCount {
+				rc := doop.ReportForConstraint{
+					Name:     fmt.Sprintf("constraint%03d", constraintIdx),
+					Metadata: doop.MetadataForConstraint{Severity: "error"},
+				}
+				for groupIdx := range groupCount {
+					rc.ViolationGroups = append(rc.ViolationGroups, doop.ViolationGroup{
+						Pattern: doop.Violation{
+							Kind:           "Pod",
+							Namespace:      fmt.Sprintf("namespace%03d", groupIdx),
+							Message:        "this is from <cluster>",
+							ObjectIdentity: map[string]string{"service": fmt.Sprintf("service%d", groupIdx%7)},
+						},
+						Instances: []doop.Violation{
+							{Name: "first-pod", Message: "this is from " + clusterName},
+							{Name: "second-pod", Message: "this is from " + clusterName},
+						},
+					})
+				}
+				rt.Constraints = append(rt.Constraints, rc)
+			}
+			report.Templates = append(report.Templates, rt)
+		}
+		reports[clusterName] = report.SetClusterName(clusterName)
+	}
+	return reports
+}
+
+func TestAggregateSyntheticReports(t *testing.T) {
+	reports := makeSyntheticReports(5, 3, 4, 6)
+	result := AggregateReports(reports, must.ReturnT(BuildFilterSet(url.Values{}))(t))
+	result.Sort()
+
+	// everything merges across clusters, so the aggregated report has the same structure as each individual report,
+	// but each violation group has the instances from all clusters
+	assert.Equal(t, len(result.Templates), 3)
+	for _, rt := range result.Templates {
+		assert.Equal(t, len(rt.Constraints), 4)
+		for _, rc := range rt.Constraints {
+			assert.Equal(t, len(rc.ViolationGroups), 6)
+			for _, vg := range rc.ViolationGroups {
+				assert.Equal(t, len(vg.Instances), 5*2)
+			}
+		}
+	}
+}
+
+func BenchmarkAggregateReports(b *testing.B) {
+	benchmarkCases := []struct {
+		ClusterCount    int
+		TemplateCount   int
+		ConstraintCount int
+		GroupCount      int
+	}{
+		{10, 10, 2, 10},
+		{100, 10, 2, 10},
+		{100, 20, 5, 50},
+		{20, 1, 1, 5000},
+	}
+	for _, bc := range benchmarkCases {
+		name := fmt.Sprintf("clusters=%d/templates=%d/constraints=%d/groups=%d",
+			bc.ClusterCount, bc.TemplateCount, bc.ConstraintCount, bc.GroupCount)
+		b.Run(name, func(b *testing.B) {
+			reports := makeSyntheticReports(bc.ClusterCount, bc.TemplateCount, bc.ConstraintCount, bc.GroupCount)
+			filterSet := must.ReturnT(BuildFilterSet(url.Values{}))(b)
+			for b.Loop() {
+				AggregateReports(reports, filterSet)
+			}
+		})
+	}
+}
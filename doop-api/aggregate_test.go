@@ -5,10 +5,13 @@ package main
 
 import (
 	"encoding/json"
+	"maps"
 	"net/url"
 	"os"
+	"slices"
 	"testing"
 
+	"github.com/sapcc/go-bits/must"
 	"go.xyrillian.de/gg/assert"
 
 	"github.com/sapcc/gatekeeper-addons/internal/doop"
@@ -21,19 +24,19 @@ func TestAggregateOfOneCluster(t *testing.T) {
 
 	// test that aggregating results from only one cluster barely changes the input if no filter is applied
 	expected := mustParseJSON[doop.AggregatedReport](t, "fixtures/output-cluster1-only.json")
-	actual := AggregateReports(inputSet, BuildFilterSet(url.Values{}))
+	actual := AggregateReports(inputSet, must.ReturnT(BuildFilterSet(url.Values{}))(t))
 	actual.Sort()
 	assert.Equal(t, actual, expected)
 
 	// test a filter that does not change anything because it exactly matches what is in the report
 	filterStr := "cluster_identity.number=one&template_kind=GkFirstTemplate&constraint_name=firstconstraint&object_identity.type=production"
-	actual = AggregateReports(inputSet, BuildFilterSet(query(filterStr)))
+	actual = AggregateReports(inputSet, must.ReturnT(BuildFilterSet(query(filterStr)))(t))
 	actual.Sort()
 	assert.Equal(t, actual, expected)
 
 	// test a filter that removes all clusters
 	filterStr = "cluster_identity.number=two"
-	actual = AggregateReports(inputSet, BuildFilterSet(query(filterStr)))
+	actual = AggregateReports(inputSet, must.ReturnT(BuildFilterSet(query(filterStr)))(t))
 	actual.Sort()
 	assert.Equal(t, actual, doop.AggregatedReport{
 		ClusterIdentities: map[string]map[string]string{},
@@ -49,13 +52,14 @@ func TestAggregateOfOneCluster(t *testing.T) {
 	}
 	for _, filterStr := range negativeFilters {
 		t.Run("filter="+filterStr, func(t *testing.T) {
-			actual = AggregateReports(inputSet, BuildFilterSet(query(filterStr)))
+			actual = AggregateReports(inputSet, must.ReturnT(BuildFilterSet(query(filterStr)))(t))
 			actual.Sort()
 			assert.Equal(t, actual, doop.AggregatedReport{
 				ClusterIdentities: map[string]map[string]string{
 					"cluster1": {"number": "one"},
 				},
 				Templates: nil,
+				Meta:      expected.Meta,
 			})
 		})
 	}
@@ -75,9 +79,63 @@ func TestAggregateOfTwoClusters(t *testing.T) {
 
 	// test merging of structures on all levels of the report
 	expected := mustParseJSON[doop.AggregatedReport](t, "fixtures/output-both-clusters.json")
-	actual := AggregateReports(inputSet, BuildFilterSet(url.Values{}))
+	actual := AggregateReports(inputSet, must.ReturnT(BuildFilterSet(url.Values{}))(t))
 	actual.Sort()
 	assert.Equal(t, actual, expected)
+
+	// test that stale reports are excluded by default, but still listed as stale
+	staleInputSet := maps.Clone(inputSet)
+	staleReport := staleInputSet["cluster4"]
+	staleReport.IsStale = true
+	staleInputSet["cluster4"] = staleReport
+	actual = AggregateReports(staleInputSet, must.ReturnT(BuildFilterSet(url.Values{}))(t))
+	actual.Sort()
+	assert.Equal(t, actual.StaleClusters, []string{"cluster4"})
+	assert.Equal(t, slices.Sorted(maps.Keys(actual.ClusterIdentities)), []string{"cluster1", "cluster2", "cluster3"})
+	assert.Equal(t, listViolationInstances(actual), []string{
+		"merge-violation-groups-inside-constraint@cluster3",
+		"merge-violations-across-clusters@cluster1",
+		"merge-violations-across-clusters@cluster2",
+	})
+
+	// test that stale reports can be included on request
+	actual = AggregateReports(staleInputSet, must.ReturnT(BuildFilterSet(query("include_stale=true")))(t))
+	actual.Sort()
+	expectedWithStale := expected
+	expectedWithStale.StaleClusters = []string{"cluster4"}
+	assert.Equal(t, actual, expectedWithStale)
+
+	// test filters on the violated object, which need to narrow down violation groups instance by instance
+	objectFilterCases := map[string][]string{
+		"q=CLUSTER2":                           {"merge-violations-across-clusters@cluster2"},
+		"namespace=test&name^=merge-violation": {"merge-violation-groups-inside-constraint@cluster3", "merge-violations-across-clusters@cluster1", "merge-violations-across-clusters@cluster2"},
+		"kind=Pod&name!=~across|inside":        {},
+		"namespace!=test":                      {},
+		"q=another&name*=*-constraint":         {"merge-violation-groups-inside-constraint@cluster3"},
+	}
+	for filterStr, expectedInstances := range objectFilterCases {
+		t.Run("filter="+filterStr, func(t *testing.T) {
+			actual := AggregateReports(inputSet, must.ReturnT(BuildFilterSet(query(filterStr)))(t))
+			actual.Sort()
+			assert.Equal(t, listViolationInstances(actual), expectedInstances)
+		})
+	}
+}
+
+// Returns "$NAME@$CLUSTER" for each violation instance in the given report.
+func listViolationInstances(report doop.AggregatedReport) []string {
+	result := []string{}
+	for _, rt := range report.Templates {
+		for _, rc := range rt.Constraints {
+			for _, vg := range rc.ViolationGroups {
+				for _, v := range vg.Instances {
+					v = v.ExpandedFrom(vg.Pattern)
+					result = append(result, v.Name+"@"+v.ClusterName)
+				}
+			}
+		}
+	}
+	return result
 }
 
 func query(input string) url.Values {
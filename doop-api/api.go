@@ -4,25 +4,57 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/gorilla/mux"
 	"github.com/sapcc/go-bits/httpapi"
 	"github.com/sapcc/go-bits/logg"
 	"github.com/sapcc/go-bits/respondwith"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
 )
 
 // API is an httpapi.API implementation.
 type API struct {
 	Downloader *Downloader
+	// nil if history recording is disabled
+	History *HistoryStore
+	// nil if violation tracking is disabled
+	Tracker         *ViolationTracker
+	violationsCache *resultCache
+}
+
+// NewAPI creates an API. The HistoryStore and the ViolationTracker may be nil.
+func NewAPI(downloader *Downloader, history *HistoryStore, tracker *ViolationTracker) API {
+	return API{
+		Downloader:      downloader,
+		History:         history,
+		Tracker:         tracker,
+		violationsCache: newResultCache(),
+	}
 }
 
 // AddTo implements the httpapi.API interface.
 func (a API) AddTo(r *mux.Router) {
 	r.Methods("GET").Path("/v2/violations").Handler(gziphandler.GzipHandler(http.HandlerFunc(a.handleGetViolations)))
+	r.Methods("GET").Path("/v2/summary").HandlerFunc(a.handleGetSummary)
+	r.Methods("GET").Path("/v2/drift").HandlerFunc(a.handleGetDrift)
+	r.Methods("GET").Path("/v2/clusters").HandlerFunc(a.handleGetClusters)
+	r.Methods("GET").Path("/v2/mutators").HandlerFunc(a.handleGetMutators)
+	r.Methods("GET").Path("/v2/history").HandlerFunc(a.handleGetHistory)
+	r.Methods("GET").Path("/v2/changes").HandlerFunc(a.handleGetChanges)
+	r.Methods("GET").Path("/ui").HandlerFunc(uiRedirectHandler)
+	r.Methods("GET").Path("/ui/").Handler(gziphandler.GzipHandler(http.HandlerFunc(a.handleGetUI)))
+	r.Methods("GET").PathPrefix("/ui/static/").Handler(uiStaticHandler)
 }
 
 // The Gzip middleware will use the first few writes to decide whether to use compression or not
@@ -51,20 +83,272 @@ func (w chunkingWriter) Write(buf []byte) (int, error) {
 func (a API) handleGetViolations(w http.ResponseWriter, r *http.Request) {
 	httpapi.IdentifyEndpoint(r, "/v2/violations")
 
-	reports, err := a.Downloader.GetReports(r.Context())
+	query := r.URL.Query()
+	vq, err := parseViolationsQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Vary", "Accept")
+
+	var body []byte
+	if atStr := query.Get("at"); atStr == "" {
+		// results for the current reports are cached, since dashboards tend to poll the same queries repeatedly
+		// (the output format may come from the Accept header, so it needs to be added to the query explicitly)
+		// (likewise, the tracking state influences the first_seen fields, so its version needs to be included as well)
+		etagQuery := maps.Clone(query)
+		etagQuery.Set("format", string(vq.Format))
+		var tracking *trackingState
+		if a.Tracker != nil {
+			tracking = a.Tracker.GetState()
+			etagQuery.Set("tracking_version", tracking.Version())
+		}
+		objStates := a.Downloader.GetObjectStates()
+		etag := computeResultETag(etagQuery, objStates)
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		body, err = a.violationsCache.GetOrCompute(etag, func() ([]byte, error) {
+			reports, reportErrs := reportsFromObjectStates(objStates)
+			return encodeViolations(reports, reportErrs, tracking, vq)
+		})
+	} else {
+		// results for history snapshots are not cached, since they are requested much less frequently
+		at, parseErr := time.Parse(time.RFC3339, atStr)
+		if parseErr != nil {
+			http.Error(w, fmt.Sprintf("invalid value for at: %q (expected RFC3339 timestamp)", atStr), http.StatusBadRequest)
+			return
+		}
+		reports, reportErrs, listErr := a.Downloader.GetReportsAt(r.Context(), at)
+		if respondwith.ErrorText(w, listErr) {
+			return
+		}
+		// first_seen is not shown for history snapshots since the tracking state only reflects the current reports
+		body, err = encodeViolations(reports, reportErrs, nil, vq)
+	}
 	if respondwith.ErrorText(w, err) {
 		return
 	}
-	result := AggregateReports(reports, BuildFilterSet(r.URL.Query()))
-	result.Sort()
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", vq.Format.ContentType())
 	w.WriteHeader(http.StatusOK)
+	_, err = chunkingWriter{inner: w}.Write(body)
+	if err != nil {
+		logg.Error("while writing violations %s: %s", vq.Format, err.Error())
+	}
+}
+
+// violationsQuery contains all settings for GET /v2/violations that influence the response body.
+type violationsQuery struct {
+	Filter     FilterSet
+	Pagination Pagination
+	Format     ViolationsFormat
+	// Only used for ViolationsFormatCSV, see ParseCSVColumns().
+	CSVColumns []string
+	// Only used to build the link to the next page.
+	URL *url.URL
+}
+
+func parseViolationsQuery(r *http.Request) (vq violationsQuery, err error) {
+	query := r.URL.Query()
+	vq.URL = r.URL
+	vq.Filter, err = BuildFilterSet(query)
+	if err != nil {
+		return vq, err
+	}
+	vq.Pagination, err = ParsePagination(query)
+	if err != nil {
+		return vq, err
+	}
+	vq.Format, err = ParseViolationsFormat(r)
+	if err != nil {
+		return vq, err
+	}
+	vq.CSVColumns, err = ParseCSVColumns(query["columns"])
+	return vq, err
+}
+
+// encodeViolations renders the response body for GET /v2/violations.
+// The tracking state may be nil if first_seen shall not be shown.
+func encodeViolations(reports map[string]doop.Report, reportErrs map[string]error, tracking *trackingState, vq violationsQuery) ([]byte, error) {
+	result := AggregateReports(reports, vq.Filter)
+	result.Sort()
+	// these formats always contain all matching violations, so pagination does not apply
+	switch vq.Format {
+	case ViolationsFormatCSV:
+		return encodeViolationsAsCSV(result, vq.CSVColumns)
+	case ViolationsFormatSARIF:
+		return encodeViolationsAsSARIF(result)
+	}
+
+	result.SetErrors(reportErrs)
+	if nextCursor := vq.Pagination.Apply(&result); nextCursor != "" {
+		result.Next = urlWithCursor(vq.URL.Path, vq.URL.Query(), nextCursor)
+	}
+	tracking.SetFirstSeen(&result)
+
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("while encoding violations json: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (a API) handleGetSummary(w http.ResponseWriter, r *http.Request) {
+	httpapi.IdentifyEndpoint(r, "/v2/summary")
+
+	query := r.URL.Query()
+	oidKeys, err := ParseSummaryGroupKeys(query["group_by"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filterSet, err := BuildFilterSet(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reports, _ := a.Downloader.GetReports()
+	respondwith.JSON(w, http.StatusOK, SummarizeReport(AggregateReports(reports, filterSet), oidKeys))
+}
+
+func (a API) handleGetDrift(w http.ResponseWriter, r *http.Request) {
+	httpapi.IdentifyEndpoint(r, "/v2/drift")
+
+	query := r.URL.Query()
+	classKeys, err := ParseDriftClassKeys(query["group_by"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filterSet, err := BuildFilterSet(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reports, _ := a.Downloader.GetReports()
+	respondwith.JSON(w, http.StatusOK, DetectDrift(reports, classKeys, filterSet))
+}
+
+func (a API) handleGetClusters(w http.ResponseWriter, r *http.Request) {
+	httpapi.IdentifyEndpoint(r, "/v2/clusters")
+
+	filterSet, err := BuildFilterSet(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	objStates := a.Downloader.GetObjectStates()
+	respondwith.JSON(w, http.StatusOK, map[string]any{"clusters": DescribeClusters(objStates, filterSet)})
+}
+
+func (a API) handleGetMutators(w http.ResponseWriter, r *http.Request) {
+	httpapi.IdentifyEndpoint(r, "/v2/mutators")
 
-	encoder := json.NewEncoder(chunkingWriter{inner: w})
-	err = encoder.Encode(&result)
+	query := r.URL.Query()
+	onlyBroken := false
+	if value := query.Get("broken"); value != "" {
+		var err error
+		onlyBroken, err = strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid value for broken: %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+	filterSet, err := BuildFilterSet(query)
 	if err != nil {
-		logg.Error("while encoding violations json: %w", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+
+	reports, _ := a.Downloader.GetReports()
+	result := AggregateMutators(reports, filterSet, onlyBroken)
+	result.Sort()
+	respondwith.JSON(w, http.StatusOK, result)
+}
+
+func (a API) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	httpapi.IdentifyEndpoint(r, "/v2/history")
+	if a.History == nil {
+		http.Error(w, "history is not enabled on this doop-api", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now()
+	if value := query.Get("to"); value != "" {
+		var err error
+		to, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid value for to: %q (expected RFC3339 timestamp)", value), http.StatusBadRequest)
+			return
+		}
+	}
+	from := to.Add(-7 * 24 * time.Hour)
+	if value := query.Get("from"); value != "" {
+		var err error
+		from, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid value for from: %q (expected RFC3339 timestamp)", value), http.StatusBadRequest)
+			return
+		}
+	}
+	groupKeys, err := a.History.ParseHistoryGroupKeys(query["group_by"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filterSet, err := BuildFilterSet(query)
+	if err == nil {
+		err = a.History.CheckFilterSet(filterSet)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	samples, err := a.History.ReadSamples(from, to)
+	if respondwith.ErrorText(w, err) {
+		return
+	}
+	respondwith.JSON(w, http.StatusOK, BuildHistoryResult(samples, groupKeys, filterSet))
+}
+
+func (a API) handleGetChanges(w http.ResponseWriter, r *http.Request) {
+	httpapi.IdentifyEndpoint(r, "/v2/changes")
+	if a.Tracker == nil {
+		http.Error(w, "violation tracking is not enabled on this doop-api", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	sinceStr := query.Get("since")
+	if sinceStr == "" {
+		http.Error(w, "missing required query argument: since", http.StatusBadRequest)
+		return
+	}
+	since, err := time.Parse(time.RFC3339, sinceStr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid value for since: %q (expected RFC3339 timestamp)", sinceStr), http.StatusBadRequest)
+		return
+	}
+	filterSet, err := BuildFilterSet(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// cluster identities are not stored in the tracking state, so they are taken from the current reports
+	reports, _ := a.Downloader.GetReports()
+	clusterIdentities := make(map[string]map[string]string, len(reports))
+	for clusterName, report := range reports {
+		clusterIdentities[clusterName] = report.ClusterIdentity
+	}
+	respondwith.JSON(w, http.StatusOK, a.Tracker.GetState().GetChanges(since, clusterIdentities, filterSet))
 }
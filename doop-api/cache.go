@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// resultCacheMaxEntries limits the memory usage of type resultCache.
+// Since an encoded result for an unfiltered query can be many megabytes large, this is deliberately small.
+const resultCacheMaxEntries = 16
+
+// resultCache holds encoded API responses, keyed by their ETag.
+// Since the ETag covers both the query and all inputs, entries never need to be invalidated;
+// outdated entries are only evicted to make room for new ones.
+type resultCache struct {
+	entries map[string][]byte
+	mutex   sync.Mutex
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{entries: make(map[string][]byte)}
+}
+
+// GetOrCompute returns the cached entry for the given ETag, or computes and caches it if it does not exist yet.
+func (c *resultCache) GetOrCompute(etag string, compute func() ([]byte, error)) ([]byte, error) {
+	c.mutex.Lock()
+	result, exists := c.entries[etag]
+	c.mutex.Unlock()
+	if exists {
+		return result, nil
+	}
+
+	// the mutex is not held during compute(), so that slow computations do not block requests for other entries
+	result, err := compute()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.entries) >= resultCacheMaxEntries {
+		// we do not track usage, so just start over; this is good enough since dashboards usually poll the same few queries
+		clear(c.entries)
+	}
+	c.entries[etag] = result
+	return result, nil
+}
+
+// computeResultETag returns a weak ETag that changes whenever the given query or anything about the given reports changes.
+// Since the ETag is also used as a cache key, it must cover everything that the response is derived from.
+func computeResultETag(query url.Values, objStates map[string]objectState) string {
+	// normalize the query: the order of keys and values does not influence the result
+	normalizedQuery := make(url.Values, len(query))
+	for key, values := range query {
+		normalizedQuery[key] = slices.Sorted(slices.Values(values))
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n", normalizedQuery.Encode())
+	for _, name := range slices.Sorted(maps.Keys(objStates)) {
+		objState := objStates[name]
+		errMsg := ""
+		if objState.Error != nil {
+			errMsg = objState.Error.Error()
+		}
+		fmt.Fprintf(hash, "%q %q %t %t %q\n", name, objState.Etag, objState.HasPayload, objState.Payload.IsStale, errMsg)
+	}
+	return fmt.Sprintf(`W/"%s"`, hex.EncodeToString(hash.Sum(nil))[:32])
+}
+
+// etagMatches checks whether the given If-None-Match header matches the given ETag.
+// As required by RFC 9110, section 13.1.2, this uses weak comparison.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
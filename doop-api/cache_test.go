@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestComputeResultETag(t *testing.T) {
+	objStates := map[string]objectState{
+		"cluster1": {Etag: "aaa", HasPayload: true},
+		"cluster2": {Etag: "bbb", HasPayload: true},
+	}
+	etag := computeResultETag(query("severity=error&severity=warning&template_kind=GkFirstTemplate"), objStates)
+
+	// the order of query arguments does not matter
+	assert.Equal(t, computeResultETag(query("template_kind=GkFirstTemplate&severity=warning&severity=error"), objStates), etag)
+
+	// but their values do
+	if computeResultETag(query("severity=error"), objStates) == etag {
+		t.Error("expected ETag to change when query changes")
+	}
+
+	// any change to the reports changes the ETag
+	changes := map[string]objectState{
+		"new etag":  {Etag: "ccc", HasPayload: true},
+		"now stale": {Etag: "bbb", HasPayload: true, Payload: doop.Report{IsStale: true}},
+		"now error": {Etag: "bbb", HasPayload: true, Error: errors.New("cannot decode report for cluster2")},
+	}
+	for desc, objState := range changes {
+		changedStates := map[string]objectState{"cluster1": objStates["cluster1"], "cluster2": objState}
+		if computeResultETag(query("severity=error&severity=warning&template_kind=GkFirstTemplate"), changedStates) == etag {
+			t.Errorf("expected ETag to change for %s", desc)
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	etag := `W/"0123456789abcdef"`
+	assert.Equal(t, etagMatches("", etag), false)
+	assert.Equal(t, etagMatches(`W/"0123456789abcdef"`, etag), true)
+	assert.Equal(t, etagMatches(`"0123456789abcdef"`, etag), true)
+	assert.Equal(t, etagMatches(`"other", W/"0123456789abcdef"`, etag), true)
+	assert.Equal(t, etagMatches(`"other"`, etag), false)
+	assert.Equal(t, etagMatches(`*`, etag), true)
+}
+
+func TestResultCache(t *testing.T) {
+	c := newResultCache()
+	computeCount := 0
+	compute := func() ([]byte, error) {
+		computeCount++
+		return []byte("result"), nil
+	}
+
+	// the second call for the same ETag is served from the cache
+	for range 2 {
+		result := must.ReturnT(c.GetOrCompute("etag1", compute))(t)
+		assert.Equal(t, string(result), "result")
+	}
+	assert.Equal(t, computeCount, 1)
+
+	// the cache does not grow indefinitely
+	for idx := range 2 * resultCacheMaxEntries {
+		_, _ = c.GetOrCompute(string(rune('A'+idx)), compute)
+	}
+	if len(c.entries) > resultCacheMaxEntries {
+		t.Errorf("expected at most %d entries, but got %d", resultCacheMaxEntries, len(c.entries))
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// ClusterInfo is the data structure that is returned by GET /v2/clusters for each report.
+type ClusterInfo struct {
+	Name            string            `json:"name"`
+	ClusterIdentity map[string]string `json:"cluster_identity"`
+	// Metadata of the report object in Swift.
+	LastModified time.Time `json:"last_modified"`
+	Etag         string    `json:"etag"`
+	// Oldest and newest audit timestamp across all constraints in this report (if any).
+	OldestAuditTime *time.Time `json:"oldest_audit_timestamp,omitempty"`
+	NewestAuditTime *time.Time `json:"newest_audit_timestamp,omitempty"`
+	Violations      int        `json:"violations"`
+	ViolationGroups int        `json:"violation_groups"`
+	IsStale         bool       `json:"stale"`
+	// If not empty, the latest version of the report could not be downloaded or decoded. All fields above that are
+	// derived from its content then refer to the last version that could be decoded (or are empty if there is none).
+	ParseError string `json:"parse_error,omitempty"`
+}
+
+// DescribeClusters builds a ClusterInfo for each report.
+// Reports that could not be decoded have an empty cluster identity, so they are
+// only included if the cluster_identity filters (if any) allow that.
+func DescribeClusters(objStates map[string]objectState, f FilterSet) []ClusterInfo {
+	result := make([]ClusterInfo, 0, len(objStates))
+	for name, objState := range objStates {
+		report := objState.Payload
+		if !f.MatchClusterIdentity(report.ClusterIdentity) {
+			continue
+		}
+
+		info := ClusterInfo{
+			Name:            name,
+			ClusterIdentity: report.ClusterIdentity,
+			LastModified:    objState.LastModified,
+			Etag:            objState.Etag,
+			IsStale:         report.IsStale,
+		}
+		if objState.Error != nil {
+			info.ParseError = objState.Error.Error()
+		}
+
+		info.OldestAuditTime, info.NewestAuditTime = auditTimeRange(report)
+		for _, rt := range report.Templates {
+			for _, rc := range rt.Constraints {
+				for _, vg := range rc.ViolationGroups {
+					info.ViolationGroups++
+					info.Violations += len(vg.Instances)
+				}
+			}
+		}
+		result = append(result, info)
+	}
+
+	slices.SortFunc(result, func(lhs, rhs ClusterInfo) int {
+		return strings.Compare(lhs.Name, rhs.Name)
+	})
+	return result
+}
+
+// auditTimeRange returns the oldest and newest audit timestamp across all constraints in the given report.
+// Both are nil if the report does not contain any valid audit timestamps.
+func auditTimeRange(report doop.Report) (oldest, newest *time.Time) {
+	for _, rt := range report.Templates {
+		for _, rc := range rt.Constraints {
+			auditTime, err := time.Parse(time.RFC3339, rc.Metadata.AuditTimestamp)
+			if err != nil {
+				continue
+			}
+			if oldest == nil || auditTime.Before(*oldest) {
+				oldest = &auditTime
+			}
+			if newest == nil || auditTime.After(*newest) {
+				newest = &auditTime
+			}
+		}
+	}
+	return oldest, newest
+}
@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestDescribeClusters(t *testing.T) {
+	lastModified := time.Date(2023, 9, 5, 9, 30, 0, 0, time.UTC)
+	auditTime := time.Date(2023, 9, 5, 9, 24, 27, 0, time.UTC)
+	objStates := map[string]objectState{
+		"cluster1": {
+			Etag:         "etag1",
+			LastModified: lastModified,
+			Payload:      mustParseJSON[doop.Report](t, "fixtures/input-cluster1.json").SetClusterName("cluster1"),
+			HasPayload:   true,
+		},
+		"cluster2": {
+			Etag:         "etag2",
+			LastModified: lastModified,
+			Error:        errors.New("cannot decode report for cluster2: unexpected end of JSON input"),
+		},
+	}
+
+	actual := DescribeClusters(objStates, must.ReturnT(BuildFilterSet(url.Values{}))(t))
+	assert.Equal(t, actual, []ClusterInfo{
+		{
+			Name:            "cluster1",
+			ClusterIdentity: map[string]string{"number": "one"},
+			LastModified:    lastModified,
+			Etag:            "etag1",
+			OldestAuditTime: &auditTime,
+			NewestAuditTime: &auditTime,
+			Violations:      1,
+			ViolationGroups: 1,
+		},
+		{
+			Name:         "cluster2",
+			LastModified: lastModified,
+			Etag:         "etag2",
+			ParseError:   "cannot decode report for cluster2: unexpected end of JSON input",
+		},
+	})
+
+	// cluster_identity filters apply
+	actual = DescribeClusters(objStates, must.ReturnT(BuildFilterSet(query("cluster_identity.number=one")))(t))
+	assert.Equal(t, len(actual), 1)
+	assert.Equal(t, actual[0].Name, "cluster1")
+}
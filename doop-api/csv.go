@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// ParseCSVColumns collects the object identity keys from the `columns` query argument.
+// If no keys are given, nil is returned and encodeViolationsAsCSV() shows all object identity keys.
+func ParseCSVColumns(columns []string) ([]string, error) {
+	if len(columns) == 0 {
+		return nil, nil
+	}
+	result := make([]string, 0, len(columns))
+	for _, value := range columns {
+		key, ok := strings.CutPrefix(value, "object_identity.")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid value for columns: %q (expected \"object_identity.$KEY\")", value)
+		}
+		if !slices.Contains(result, key) {
+			result = append(result, key)
+		}
+	}
+	return result, nil
+}
+
+// encodeViolationsAsCSV renders the given report as a table with one row per violation instance.
+// If oidKeys is nil, there is one column for each object identity key that appears anywhere in the report.
+func encodeViolationsAsCSV(report doop.AggregatedReport, oidKeys []string) ([]byte, error) {
+	if oidKeys == nil {
+		keySet := make(map[string]struct{})
+		for _, rt := range report.Templates {
+			for _, rc := range rt.Constraints {
+				for _, vg := range rc.ViolationGroups {
+					for _, v := range vg.Instances {
+						for key := range v.ExpandedFrom(vg.Pattern).ObjectIdentity {
+							keySet[key] = struct{}{}
+						}
+					}
+				}
+			}
+		}
+		oidKeys = slices.Sorted(maps.Keys(keySet))
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"cluster", "template_kind", "constraint_name", "severity", "kind", "namespace", "name", "message"}
+	for _, key := range oidKeys {
+		header = append(header, "object_identity."+key)
+	}
+	err := w.Write(header)
+	if err != nil {
+		return nil, fmt.Errorf("while encoding violations csv: %w", err)
+	}
+
+	row := make([]string, len(header))
+	for _, rt := range report.Templates {
+		for _, rc := range rt.Constraints {
+			for _, vg := range rc.ViolationGroups {
+				for _, instance := range vg.Instances {
+					v := instance.ExpandedFrom(vg.Pattern)
+					row = append(row[:0], v.ClusterName, rt.Kind, rc.Name, rc.Metadata.Severity, v.Kind, v.Namespace, v.Name, v.Message)
+					for _, key := range oidKeys {
+						row = append(row, v.ObjectIdentity[key])
+					}
+					for idx, cell := range row {
+						row[idx] = escapeCSVFormula(cell)
+					}
+					err := w.Write(row)
+					if err != nil {
+						return nil, fmt.Errorf("while encoding violations csv: %w", err)
+					}
+				}
+			}
+		}
+	}
+
+	w.Flush()
+	err = w.Error()
+	if err != nil {
+		return nil, fmt.Errorf("while encoding violations csv: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// escapeCSVFormula prevents spreadsheet applications from interpreting the given cell as a formula.
+// Since violation messages and object names can be chosen by cluster users, this would otherwise allow formula injection.
+func escapeCSVFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
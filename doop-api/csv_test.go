@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestEncodeViolationsAsCSV(t *testing.T) {
+	inputSet := map[string]doop.Report{
+		"cluster1": mustParseJSON[doop.Report](t, "fixtures/input-cluster1.json").SetClusterName("cluster1"),
+		"cluster2": mustParseJSON[doop.Report](t, "fixtures/input-cluster2.json").SetClusterName("cluster2"),
+		"cluster3": mustParseJSON[doop.Report](t, "fixtures/input-cluster3.json").SetClusterName("cluster3"),
+		"cluster4": mustParseJSON[doop.Report](t, "fixtures/input-cluster4.json").SetClusterName("cluster4"),
+	}
+	encode := func(queryStr string) string {
+		q := query(queryStr)
+		vq := violationsQuery{
+			Filter:     must.ReturnT(BuildFilterSet(q))(t),
+			Format:     ViolationsFormatCSV,
+			CSVColumns: must.ReturnT(ParseCSVColumns(q["columns"]))(t),
+			URL:        &url.URL{Path: "/v2/violations", RawQuery: queryStr},
+		}
+		return string(must.ReturnT(encodeViolations(inputSet, nil, nil, vq))(t))
+	}
+
+	// without selected columns, all object identity keys are shown
+	assert.Equal(t, encode(""), `cluster,template_kind,constraint_name,severity,kind,namespace,name,message,object_identity.type
+cluster3,GkFirstTemplate,firstconstraint,info,Pod,test,merge-violation-groups-inside-constraint,this is in another violation group,production
+cluster1,GkFirstTemplate,firstconstraint,info,Pod,test,merge-violations-across-clusters,this is from cluster1,production
+cluster2,GkFirstTemplate,firstconstraint,info,Pod,test,merge-violations-across-clusters,this is from cluster2,production
+cluster4,GkFirstTemplate,secondconstraint,info,Pod,test,merge-constraint-inside-templates,this is in another constraint,production
+`)
+
+	// filters are respected, and pagination does not apply
+	assert.Equal(t, encode("constraint_name=firstconstraint&cluster_identity.number!=three&limit=1&columns=object_identity.team"),
+		`cluster,template_kind,constraint_name,severity,kind,namespace,name,message,object_identity.team
+cluster1,GkFirstTemplate,firstconstraint,info,Pod,test,merge-violations-across-clusters,this is from cluster1,
+cluster2,GkFirstTemplate,firstconstraint,info,Pod,test,merge-violations-across-clusters,this is from cluster2,
+`)
+}
+
+func TestParseCSVColumns(t *testing.T) {
+	actual := must.ReturnT(ParseCSVColumns([]string{"object_identity.team", "object_identity.service", "object_identity.team"}))(t)
+	assert.Equal(t, actual, []string{"team", "service"})
+
+	_, err := ParseCSVColumns([]string{"severity"})
+	if err == nil {
+		t.Error("expected error for columns=severity, but got none")
+	}
+}
+
+func TestEscapeCSVFormula(t *testing.T) {
+	testCases := map[string]string{
+		"":                         "",
+		"coredns-1":                "coredns-1",
+		"image uses -latest tag":   "image uses -latest tag",
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+1":                       "'+1",
+		"-1":                       "'-1",
+		"@SUM(A1:A2)":              "'@SUM(A1:A2)",
+		"\tindented":               "'\tindented",
+	}
+	for input, expected := range testCases {
+		assert.Equal(t, escapeCSVFormula(input), expected)
+	}
+}
@@ -6,87 +6,436 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"net/url"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sapcc/go-bits/logg"
+	"github.com/sapcc/go-bits/syncext"
 	"go.xyrillian.de/schwift/v2"
 
 	"github.com/sapcc/gatekeeper-addons/internal/doop"
 )
 
 // Downloader pulls doop-analyzer reports from Swift.
+//
+// The current reports are pulled by Refresh(), which is usually called periodically by Run() in the background.
+// Each refresh publishes an immutable snapshot of all reports, which is what GetReports() and GetObjectStates() read.
+// History snapshots are only pulled on demand by GetReportsAt().
 type Downloader struct {
 	container *schwift.Container
-	objects   map[string]*objectState
-	mutex     sync.Mutex
+	// If not zero, reports that have not been updated for this long are marked as stale.
+	stalenessThreshold time.Duration
+	// How many reports may be downloaded at the same time during Refresh().
+	concurrency int
+	snapshot    atomic.Pointer[downloaderSnapshot]
+	// Cache for GetReportsAt(). Since history snapshots never change, this does not need to be refreshed.
+	// It is bounded by evicting the least recently used entries (which also takes care of snapshots that Swift has
+	// deleted in the meantime, since those are not used anymore).
+	historyObjects map[string]historyCacheEntry
+	historyMutex   sync.Mutex
 }
 
-// NewDownloader creates a Downloader.
-func NewDownloader(container *schwift.Container) *Downloader {
+// How many sets of history snapshots (i.e. one snapshot per cluster) are kept in the cache for GetReportsAt().
+const historyCacheCapacity = 4
+
+// historyCacheEntry appears in type Downloader.
+type historyCacheEntry struct {
+	State    objectState
+	LastUsed time.Time
+}
+
+// downloaderSnapshot is the immutable result of a Downloader.Refresh() call.
+type downloaderSnapshot struct {
+	// Keys are object names.
+	Objects     map[string]objectState
+	RefreshedAt time.Time
+}
+
+// NewDownloader creates a Downloader. Before any reports can be read from it, Refresh() must be called at least once.
+func NewDownloader(container *schwift.Container, stalenessThreshold time.Duration, concurrency int) *Downloader {
 	return &Downloader{
-		container: container,
-		objects:   make(map[string]*objectState),
+		container:          container,
+		stalenessThreshold: stalenessThreshold,
+		concurrency:        concurrency,
+		historyObjects:     make(map[string]historyCacheEntry),
 	}
 }
 
-// GetReports returns all most recent doop-analyzer reports in their yet unparsed form.
-func (d *Downloader) GetReports(ctx context.Context) (map[string]doop.Report, error) {
-	objInfos, err := d.container.Objects().CollectDetailed(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("cannot list reports in Swift: %w", err)
+// Run calls Refresh() at the given interval until `ctx` expires.
+// Errors are logged, and the previous snapshot remains in use for all objects that could not be refreshed.
+func (d *Downloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.logRefreshErrors(d.Refresh(ctx))
+		}
 	}
+}
 
-	d.mutex.Lock()
-	defer d.mutex.Unlock()
+// Refresh lists all current reports in Swift, downloads those that have changed since the previous refresh,
+// and publishes a new snapshot. If some reports could not be downloaded or decoded, the last version that could be
+// decoded is retained in the new snapshot. Download errors are returned in `downloadErr` after the new snapshot has
+// been published. If the reports cannot be listed, `err` is returned and no new snapshot is published.
+func (d *Downloader) Refresh(ctx context.Context) (downloadErr, err error) {
+	objInfos, err := d.listReports(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	//NOTE: `objInfo` is the latest information from Swift about a report object.
 	// `objState` is what this process knows about a report object.
-	result := make(map[string]doop.Report, len(objInfos))
+	previous := d.snapshot.Load()
+	var (
+		objects = make(map[string]objectState, len(objInfos))
+		errs    []error
+		mutex   sync.Mutex
+		wg      sync.WaitGroup
+		sem     = syncext.NewSemaphore(d.concurrency)
+	)
 	for _, objInfo := range objInfos {
 		name := objInfo.Object.Name()
-		objState := d.objects[name]
+		var (
+			previousState objectState
+			exists        bool
+		)
+		if previous != nil {
+			previousState, exists = previous.Objects[name]
+		}
+		if exists && !previousState.NeedsUpdate(objInfo) {
+			mutex.Lock()
+			objects[name] = previousState
+			mutex.Unlock()
+			continue
+		}
 
-		if objState.NeedsUpdate(objInfo) {
-			logg.Debug("pulling updated report for %s", name)
-			if objState == nil {
-				objState = &objectState{}
-				d.objects[name] = objState
-			}
-			objState.SizeBytes = objInfo.SizeBytes
-			objState.Etag = objInfo.Etag
-			objState.LastModified = objInfo.LastModified
-			payloadBytes, err := objInfo.Object.Download(ctx, nil).AsByteSlice()
+		wg.Go(func() {
+			sem.Run(func() {
+				downloadedState, err := downloadObject(ctx, objInfo, name)
+				objState := nextObjectState(previousState, downloadedState, err)
+
+				mutex.Lock()
+				defer mutex.Unlock()
+				objects[name] = objState
+				if err != nil {
+					errs = append(errs, err)
+				}
+			})
+		})
+	}
+	wg.Wait()
+
+	d.snapshot.Store(&downloaderSnapshot{
+		Objects:     objects,
+		RefreshedAt: time.Now(),
+	})
+	return errors.Join(errs...), nil
+}
+
+// logRefreshErrors logs the errors returned by Refresh(). Neither of them is fatal: the previous snapshot (if any)
+// remains in use for everything that could not be refreshed.
+func (d *Downloader) logRefreshErrors(downloadErr, err error) {
+	if err != nil {
+		logg.Error("could not refresh reports: %s", err.Error())
+	}
+	if downloadErr != nil {
+		logg.Error("could not refresh some reports: %s", downloadErr.Error())
+	}
+}
+
+// GetReports returns all most recent doop-analyzer reports from the latest snapshot.
+//
+// If the latest version of a report could not be downloaded or decoded, the last good version of it is returned instead.
+// If there is no good version, the report is skipped. In both cases, the respective error is included in the
+// second return value, keyed by cluster name.
+func (d *Downloader) GetReports() (reports map[string]doop.Report, reportErrs map[string]error) {
+	return reportsFromObjectStates(d.GetObjectStates())
+}
+
+// reportsFromObjectStates is the part of GetReports that works on the result of GetObjectStates.
+func reportsFromObjectStates(objStates map[string]objectState) (reports map[string]doop.Report, reportErrs map[string]error) {
+	reports = make(map[string]doop.Report, len(objStates))
+	reportErrs = make(map[string]error)
+	for name, objState := range objStates {
+		if objState.Error != nil {
+			reportErrs[name] = objState.Error
+		}
+		if objState.HasPayload {
+			reports[name] = objState.Payload
+		}
+	}
+	return reports, reportErrs
+}
+
+// GetObjectStates is like GetReports, but returns the full objectState for each report,
+// including the metadata of their Swift objects.
+func (d *Downloader) GetObjectStates() map[string]objectState {
+	snapshot := d.snapshot.Load()
+	if snapshot == nil {
+		return nil
+	}
+
+	now := time.Now()
+	result := make(map[string]objectState, len(snapshot.Objects))
+	for name, objState := range snapshot.Objects {
+		// since the snapshot is shared, IsStale can only be set on the copy that we return here
+		objState.Payload.IsStale = objState.IsStale(d.stalenessThreshold, now)
+		result[name] = objState
+	}
+	return result
+}
+
+// GetReportsAt is like GetReports, but returns the history snapshots that were current at the given time.
+// Only clusters that currently have a report are considered. Clusters that do not have a history snapshot from before
+// that time are not included in the result. Unlike GetReports, this downloads from Swift on demand. History snapshots
+// that cannot be downloaded or decoded are skipped, and the respective errors are included in the second return value.
+func (d *Downloader) GetReportsAt(ctx context.Context, at time.Time) (reports map[string]doop.Report, reportErrs map[string]error, err error) {
+	var (
+		objInfosByName = make(map[string]schwift.ObjectInfo)
+		errs           []error
+		mutex          sync.Mutex
+		wg             sync.WaitGroup
+		sem            = syncext.NewSemaphore(d.concurrency)
+	)
+	for clusterName := range d.GetObjectStates() {
+		wg.Go(func() {
+			sem.Run(func() {
+				objInfos, err := d.listHistorySnapshots(ctx, clusterName, at)
+				mutex.Lock()
+				defer mutex.Unlock()
+				if err != nil {
+					errs = append(errs, err)
+					return
+				}
+				for _, objInfo := range objInfos {
+					objInfosByName[objInfo.Object.Name()] = objInfo
+				}
+			})
+		})
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	snapshots := selectHistorySnapshots(slices.Collect(maps.Keys(objInfosByName)), at)
+
+	d.historyMutex.Lock()
+	defer d.historyMutex.Unlock()
+
+	now := time.Now()
+	reports = make(map[string]doop.Report, len(snapshots))
+	reportErrs = make(map[string]error)
+	for clusterName, objectName := range snapshots {
+		objInfo := objInfosByName[objectName]
+		entry, exists := d.historyObjects[objectName]
+		if !exists || entry.State.NeedsUpdate(objInfo) {
+			entry.State, err = downloadObject(ctx, objInfo, clusterName)
 			if err != nil {
-				return nil, fmt.Errorf("cannot download report for %s from Swift: %w", name, err)
+				reportErrs[clusterName] = err
+				continue
 			}
-			var payload doop.Report
-			err = json.Unmarshal(payloadBytes, &payload)
+		}
+		entry.LastUsed = now
+		d.historyObjects[objectName] = entry
+
+		if entry.State.Error != nil {
+			reportErrs[clusterName] = entry.State.Error
+		} else {
+			reports[clusterName] = entry.State.Payload
+		}
+	}
+	d.evictHistoryObjects(historyCacheCapacity * len(snapshots))
+	return reports, reportErrs, nil
+}
+
+// listReports lists all report objects in Swift. The history snapshots and the state of doop-api are stored in the
+// same container, and usually outnumber the reports by far, so the listing does not descend into their pseudo-directories.
+func (d *Downloader) listReports(ctx context.Context) ([]schwift.ObjectInfo, error) {
+	iter := d.container.Objects()
+	iter.Delimiter = "/"
+	entries, err := iter.CollectDetailed(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list reports in Swift: %w", err)
+	}
+
+	var result []schwift.ObjectInfo
+	for _, entry := range entries {
+		switch entry.SubDirectory {
+		case "":
+			result = append(result, entry)
+		case doop.HistoryObjectPrefix, doop.APIStateObjectPrefix:
+			continue
+		default:
+			// report object names may contain slashes (e.g. "region/cluster1")
+			iter := d.container.Objects()
+			iter.Prefix = entry.SubDirectory
+			objInfos, err := iter.CollectDetailed(ctx)
 			if err != nil {
-				return nil, fmt.Errorf("cannot decode report for %s: %w", name, err)
+				return nil, fmt.Errorf("cannot list reports below %s in Swift: %w", entry.SubDirectory, err)
 			}
-			payload.SetClusterName(name)
-			objState.Payload = payload
+			result = append(result, objInfos...)
 		}
+	}
+	return result, nil
+}
 
-		result[name] = objState.Payload
+// listHistorySnapshots lists the history snapshots of the given cluster that were taken at or before the given time.
+func (d *Downloader) listHistorySnapshots(ctx context.Context, clusterName string, at time.Time) ([]schwift.ObjectInfo, error) {
+	iter := d.container.Objects()
+	iter.Prefix = doop.HistoryObjectPrefix + clusterName + "/"
+	// since the timestamps in the object names sort lexicographically, the snapshots after `at` can be excluded from
+	// the listing; the end marker is exclusive, so it needs to be the first possible object name after `at`
+	endMarker := doop.HistoryObjectName(clusterName, at.Truncate(time.Second).Add(time.Second))
+	iter.Options = &schwift.RequestOptions{Values: url.Values{"end_marker": {endMarker}}}
+	objInfos, err := iter.CollectDetailed(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list history snapshots for %s in Swift: %w", clusterName, err)
 	}
 
-	return result, nil
+	// if cluster names are nested (e.g. "region" and "region/cluster1"), the prefix may match snapshots of other clusters
+	return slices.DeleteFunc(objInfos, func(objInfo schwift.ObjectInfo) bool {
+		snapshotClusterName, _, ok := doop.ParseHistoryObjectName(objInfo.Object.Name())
+		return !ok || snapshotClusterName != clusterName
+	}), nil
+}
+
+// evictHistoryObjects removes the least recently used entries from the cache for GetReportsAt(),
+// until at most `capacity` entries remain. The caller must hold historyMutex.
+func (d *Downloader) evictHistoryObjects(capacity int) {
+	if len(d.historyObjects) <= capacity {
+		return
+	}
+	objectNames := slices.SortedFunc(maps.Keys(d.historyObjects), func(lhs, rhs string) int {
+		return d.historyObjects[rhs].LastUsed.Compare(d.historyObjects[lhs].LastUsed)
+	})
+	for _, objectName := range objectNames[capacity:] {
+		delete(d.historyObjects, objectName)
+	}
+}
+
+// selectHistorySnapshots finds the most recent history snapshot at or before the given time for each cluster.
+// The result maps cluster names to object names.
+func selectHistorySnapshots(objectNames []string, at time.Time) map[string]string {
+	result := make(map[string]string)
+	snapshotTimes := make(map[string]time.Time)
+	for _, objectName := range objectNames {
+		clusterName, snapshotTime, ok := doop.ParseHistoryObjectName(objectName)
+		if !ok || snapshotTime.After(at) {
+			continue
+		}
+		if _, exists := result[clusterName]; !exists || snapshotTime.After(snapshotTimes[clusterName]) {
+			result[clusterName] = objectName
+			snapshotTimes[clusterName] = snapshotTime
+		}
+	}
+	return result
+}
+
+// downloadObject downloads and decodes the given report object.
+// Errors during decoding are not returned, but recorded in the objectState.
+func downloadObject(ctx context.Context, objInfo schwift.ObjectInfo, clusterName string) (objectState, error) {
+	name := objInfo.Object.Name()
+	logg.Debug("pulling updated report for %s", name)
+	payloadBytes, err := objInfo.Object.Download(ctx, nil).AsByteSlice()
+	if err != nil {
+		return objectState{}, fmt.Errorf("cannot download report for %s from Swift: %w", name, err)
+	}
+	payload, err := decodeReport(clusterName, payloadBytes)
+	if err != nil {
+		// this is not fatal: the report is skipped (or its last good version is used) until a decodable version is uploaded;
+		// for errUnsupportedSchemaVersion, this usually means that doop-analyzer was upgraded before doop-api
+		logg.Error(err.Error())
+	}
+	return objectState{
+		SizeBytes:    objInfo.SizeBytes,
+		Etag:         objInfo.Etag,
+		LastModified: objInfo.LastModified,
+		Payload:      payload,
+		HasPayload:   err == nil,
+		Error:        err,
+	}, nil
+}
+
+// nextObjectState computes the new state of an object after an attempt to download its latest version.
+// If the download failed or the latest version could not be decoded, the last good version is retained.
+func nextObjectState(previous, downloaded objectState, downloadErr error) objectState {
+	if downloadErr != nil {
+		// keep the metadata of the previous version, so that the download is retried during the next refresh
+		result := previous
+		result.Error = downloadErr
+		return result
+	}
+	if downloaded.Error != nil && previous.HasPayload {
+		// keep serving the last good version of this report
+		downloaded.Payload = previous.Payload
+		downloaded.HasPayload = true
+	}
+	return downloaded
 }
 
 type objectState struct {
 	SizeBytes    uint64
 	Etag         string
 	LastModified time.Time
-	Payload      doop.Report
+	// If the latest version of the object could not be decoded, this is the last version that could be decoded.
+	Payload doop.Report
+	// If false, Payload is empty because no version of the object could be decoded yet.
+	HasPayload bool
+	// If not nil, the latest version of the object could not be downloaded or decoded.
+	// This can be errUnsupportedSchemaVersion if the report has a schema version that we do not understand.
+	Error error
 }
 
-func (os *objectState) NeedsUpdate(oi schwift.ObjectInfo) bool {
-	// if we don't have any state locally yet, we definitely need to update
-	if os == nil {
+var errUnsupportedSchemaVersion = errors.New("unsupported schema version")
+
+// decodeReport parses a report downloaded from Swift, and prepares it for aggregation.
+func decodeReport(name string, buf []byte) (doop.Report, error) {
+	// check the schema version first, since reports with a newer schema might not decode into type doop.Report correctly
+	var header struct {
+		Meta struct {
+			SchemaVersion int `json:"schema_version"`
+		} `json:"meta"`
+	}
+	err := json.Unmarshal(buf, &header)
+	if err != nil {
+		return doop.Report{}, fmt.Errorf("cannot decode report for %s: %w", name, err)
+	}
+	if header.Meta.SchemaVersion > doop.CurrentSchemaVersion {
+		return doop.Report{}, fmt.Errorf("cannot decode report for %s: %w %d (this doop-api only understands up to version %d)",
+			name, errUnsupportedSchemaVersion, header.Meta.SchemaVersion, doop.CurrentSchemaVersion)
+	}
+
+	var payload doop.Report
+	err = json.Unmarshal(buf, &payload)
+	if err != nil {
+		return doop.Report{}, fmt.Errorf("cannot decode report for %s: %w", name, err)
+	}
+	return payload.SetClusterName(name), nil
+}
+
+// IsStale returns whether the report has not been updated for longer than the given threshold,
+// either because the analyzer stopped uploading it, or because the audit timestamps in it are outdated.
+// A zero threshold disables the staleness check.
+func (os objectState) IsStale(threshold time.Duration, now time.Time) bool {
+	if threshold == 0 {
+		return false
+	}
+	if now.Sub(os.LastModified) > threshold {
 		return true
 	}
+	_, newestAuditTime := auditTimeRange(os.Payload)
+	return newestAuditTime != nil && now.Sub(*newestAuditTime) > threshold
+}
+
+func (os objectState) NeedsUpdate(oi schwift.ObjectInfo) bool {
 	return os.SizeBytes != oi.SizeBytes || os.Etag != oi.Etag || os.LastModified != oi.LastModified
 }
@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestDecodeReport(t *testing.T) {
+	// reports from older analyzers do not have a schema version
+	report, err := decodeReport("cluster1", []byte(`{"cluster_identity":{"number":"one"},"templates":[]}`))
+	must.SucceedT(t, err)
+	assert.Equal(t, report.SchemaVersion(), 0)
+
+	// reports with the current schema version are accepted
+	buf := fmt.Sprintf(`{"meta":{"schema_version":%d,"analyzer_version":"1.2.3","collected_at":"2023-08-01T10:00:00Z","durations":{"gather_secs":1.5}},"cluster_identity":{"number":"one"},"templates":[]}`,
+		doop.CurrentSchemaVersion)
+	report, err = decodeReport("cluster1", []byte(buf))
+	must.SucceedT(t, err)
+	assert.Equal(t, *report.Meta, doop.ReportMeta{
+		SchemaVersion:   doop.CurrentSchemaVersion,
+		AnalyzerVersion: "1.2.3",
+		CollectedAt:     "2023-08-01T10:00:00Z",
+		Durations:       doop.ReportDurations{GatherSecs: 1.5},
+	})
+
+	// reports with a newer schema version are rejected, even if their content is incompatible with our type doop.Report
+	buf = fmt.Sprintf(`{"meta":{"schema_version":%d},"templates":{"this":"is not a list"}}`, doop.CurrentSchemaVersion+1)
+	_, err = decodeReport("cluster1", []byte(buf))
+	if !errors.Is(err, errUnsupportedSchemaVersion) {
+		t.Errorf("expected errUnsupportedSchemaVersion, but got %v", err)
+	}
+}
+
+func TestSelectHistorySnapshots(t *testing.T) {
+	objectNames := []string{
+		"cluster1",
+		"history/cluster1/2023-08-01T09:00:00Z.json",
+		"history/cluster1/2023-08-01T10:00:00Z.json",
+		"history/cluster1/2023-08-01T11:00:00Z.json",
+		"history/cluster2/2023-08-01T10:30:00Z.json",
+		"history/region/cluster3/2023-08-01T08:00:00Z.json",
+		"history/cluster4/not-a-timestamp.json",
+	}
+	at := time.Date(2023, 8, 1, 10, 15, 0, 0, time.UTC)
+
+	// cluster2 only has a snapshot from after the given time, so it does not appear in the result
+	assert.Equal(t, selectHistorySnapshots(objectNames, at), map[string]string{
+		"cluster1":        "history/cluster1/2023-08-01T10:00:00Z.json",
+		"region/cluster3": "history/region/cluster3/2023-08-01T08:00:00Z.json",
+	})
+
+	// a snapshot taken exactly at the given time is included
+	at = time.Date(2023, 8, 1, 10, 30, 0, 0, time.UTC)
+	assert.Equal(t, selectHistorySnapshots(objectNames, at), map[string]string{
+		"cluster1":        "history/cluster1/2023-08-01T10:00:00Z.json",
+		"cluster2":        "history/cluster2/2023-08-01T10:30:00Z.json",
+		"region/cluster3": "history/region/cluster3/2023-08-01T08:00:00Z.json",
+	})
+}
+
+func TestEvictHistoryObjects(t *testing.T) {
+	d := NewDownloader(nil, 0, 1)
+	now := time.Now()
+	for idx := range 5 {
+		objectName := fmt.Sprintf("history/cluster1/2023-08-01T1%d:00:00Z.json", idx)
+		d.historyObjects[objectName] = historyCacheEntry{LastUsed: now.Add(time.Duration(idx) * time.Minute)}
+	}
+
+	// nothing is evicted while the capacity is not exceeded
+	d.evictHistoryObjects(5)
+	assert.Equal(t, len(d.historyObjects), 5)
+
+	// otherwise, the least recently used entries are evicted
+	d.evictHistoryObjects(2)
+	assert.Equal(t, slices.Sorted(maps.Keys(d.historyObjects)), []string{
+		"history/cluster1/2023-08-01T13:00:00Z.json",
+		"history/cluster1/2023-08-01T14:00:00Z.json",
+	})
+}
+
+func TestObjectStateIsStale(t *testing.T) {
+	now := time.Date(2023, 9, 6, 12, 0, 0, 0, time.UTC)
+	report := mustParseJSON[doop.Report](t, "fixtures/input-cluster1.json") // newest audit timestamp is 2023-09-05T09:24:27Z
+
+	// fresh object with fresh audit timestamps
+	objState := objectState{LastModified: now.Add(-5 * time.Minute), Payload: report}
+	assert.Equal(t, objState.IsStale(48*time.Hour, now), false)
+
+	// fresh object with outdated audit timestamps
+	assert.Equal(t, objState.IsStale(24*time.Hour, now), true)
+
+	// outdated object
+	objState.LastModified = now.Add(-72 * time.Hour)
+	assert.Equal(t, objState.IsStale(48*time.Hour, now), true)
+
+	// zero threshold disables the check
+	assert.Equal(t, objState.IsStale(0, now), false)
+}
+
+func TestGetReportsFromSnapshot(t *testing.T) {
+	d := NewDownloader(nil, 24*time.Hour, 1)
+	reports, reportErrs := d.GetReports()
+	assert.Equal(t, len(reports), 0)
+	assert.Equal(t, len(reportErrs), 0)
+
+	now := time.Now()
+	oldReport := doop.Report{ClusterIdentity: map[string]string{"number": "three"}}
+	d.snapshot.Store(&downloaderSnapshot{
+		Objects: map[string]objectState{
+			"fresh":       {LastModified: now, Payload: doop.Report{ClusterIdentity: map[string]string{"number": "one"}}, HasPayload: true},
+			"stale":       {LastModified: now.Add(-48 * time.Hour), Payload: doop.Report{ClusterIdentity: map[string]string{"number": "two"}}, HasPayload: true},
+			"broken":      {LastModified: now, Payload: oldReport, HasPayload: true, Error: errors.New("cannot decode report for broken")},
+			"unsupported": {LastModified: now, Error: errUnsupportedSchemaVersion},
+		},
+		RefreshedAt: now,
+	})
+
+	// reports without a good version are skipped, broken reports are served in their last good version,
+	// and staleness is computed at read time
+	reports, reportErrs = d.GetReports()
+	assert.Equal(t, reports, map[string]doop.Report{
+		"fresh":  {ClusterIdentity: map[string]string{"number": "one"}},
+		"stale":  {ClusterIdentity: map[string]string{"number": "two"}, IsStale: true},
+		"broken": oldReport,
+	})
+	assert.Equal(t, reportErrs, map[string]error{
+		"broken":      errors.New("cannot decode report for broken"),
+		"unsupported": errUnsupportedSchemaVersion,
+	})
+
+	// the snapshot itself is not modified by this
+	assert.Equal(t, d.snapshot.Load().Objects["stale"].Payload.IsStale, false)
+}
+
+func TestNextObjectState(t *testing.T) {
+	goodReport := doop.Report{ClusterIdentity: map[string]string{"number": "one"}}
+	previous := objectState{Etag: "old", Payload: goodReport, HasPayload: true}
+	decodeErr := errors.New("cannot decode report for cluster1")
+	downloadErr := errors.New("cannot download report for cluster1 from Swift")
+
+	// successful download replaces the previous state
+	newReport := doop.Report{ClusterIdentity: map[string]string{"number": "two"}}
+	downloaded := objectState{Etag: "new", Payload: newReport, HasPayload: true}
+	assert.Equal(t, nextObjectState(previous, downloaded, nil), downloaded)
+
+	// decode error keeps the last good payload, but takes the new metadata (so that the same version is not downloaded again)
+	downloaded = objectState{Etag: "new", Error: decodeErr}
+	assert.Equal(t, nextObjectState(previous, downloaded, nil),
+		objectState{Etag: "new", Payload: goodReport, HasPayload: true, Error: decodeErr})
+
+	// download error keeps the previous state including its metadata (so that the download is retried)
+	assert.Equal(t, nextObjectState(previous, objectState{}, downloadErr),
+		objectState{Etag: "old", Payload: goodReport, HasPayload: true, Error: downloadErr})
+
+	// without a previous state, there is no payload to fall back to
+	assert.Equal(t, nextObjectState(objectState{}, downloaded, nil), downloaded)
+}
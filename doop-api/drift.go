@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// DriftReport is the data structure that is returned by GET /v2/drift.
+type DriftReport struct {
+	Drifts []Drift `json:"drifts"`
+}
+
+// Drift describes a ConstraintTemplate or constraint that is defined differently across the clusters of one class.
+type Drift struct {
+	// The cluster_identity values that are shared by all clusters in this class (only those keys that were used for grouping).
+	Class        map[string]string `json:"class"`
+	TemplateKind string            `json:"template_kind"`
+	// If empty, this describes a drift of the ConstraintTemplate itself.
+	ConstraintName string         `json:"constraint_name,omitempty"`
+	Variants       []DriftVariant `json:"variants"`
+	// Only filled if the reports contain full specs.
+	Fields []DriftField `json:"fields,omitempty"`
+}
+
+// DriftVariant appears in type Drift. It lists all clusters that share the same definition.
+type DriftVariant struct {
+	// Empty if Absent is true.
+	Hash string `json:"hash"`
+	// If true, this variant lists the clusters where the definition does not exist at all.
+	Absent   bool     `json:"absent,omitempty"`
+	Clusters []string `json:"clusters"`
+}
+
+// DriftField appears in type Drift. It describes a single spec field whose value differs between clusters.
+type DriftField struct {
+	// A path into the spec like "parameters.foo" or "match.kinds[0].kinds[1]".
+	Path string `json:"path"`
+	// Keys are cluster names. If the field does not exist in a cluster's spec, the value is null.
+	Values map[string]any `json:"values"`
+}
+
+// ParseDriftClassKeys collects the cluster identity keys from the `group_by` query argument.
+func ParseDriftClassKeys(groupBy []string) ([]string, error) {
+	result := make([]string, 0, len(groupBy))
+	for _, value := range groupBy {
+		key, ok := strings.CutPrefix(value, "cluster_identity.")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid value for group_by: %q (expected \"cluster_identity.$KEY\")", value)
+		}
+		result = append(result, key)
+	}
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
+
+// DetectDrift compares the definitions in all given reports. Clusters are
+// sorted into classes by the values of the given cluster identity keys, and
+// only clusters within the same class are compared with each other.
+func DetectDrift(reports map[string]doop.Report, classKeys []string, f FilterSet) DriftReport {
+	// sort clusters into classes
+	clustersByClass := make(map[string][]string)
+	classByID := make(map[string]map[string]string)
+	for clusterName, report := range reports {
+		if !f.MatchClusterIdentity(report.ClusterIdentity) || !f.MatchStaleness(report.IsStale) {
+			continue
+		}
+		class := make(map[string]string, len(classKeys))
+		values := make([]string, len(classKeys))
+		for idx, key := range classKeys {
+			class[key] = report.ClusterIdentity[key]
+			values[idx] = report.ClusterIdentity[key]
+		}
+		classID := strings.Join(values, "\000")
+		clustersByClass[classID] = append(clustersByClass[classID], clusterName)
+		classByID[classID] = class
+	}
+
+	result := DriftReport{Drifts: []Drift{}}
+	for _, classID := range slices.Sorted(maps.Keys(clustersByClass)) {
+		clusterNames := clustersByClass[classID]
+		slices.Sort(clusterNames)
+		for _, d := range detectDriftInClass(reports, clusterNames, f) {
+			d.Class = classByID[classID]
+			result.Drifts = append(result.Drifts, d)
+		}
+	}
+	return result
+}
+
+// definitionInstance is a DefinitionForTemplate or DefinitionForConstraint as observed in one specific cluster.
+type definitionInstance struct {
+	ClusterName string
+	Hash        string
+	Spec        map[string]any
+}
+
+func detectDriftInClass(reports map[string]doop.Report, clusterNames []string, f FilterSet) []Drift {
+	// collect all definitions by kind and name
+	templateDefs := make(map[string][]definitionInstance)
+	constraintDefs := make(map[string]map[string][]definitionInstance)
+	// reports without any definitions (e.g. from older analyzers) cannot tell whether a definition is absent
+	var reportingClusterNames []string
+	for _, clusterName := range clusterNames {
+		if len(reports[clusterName].Definitions) > 0 {
+			reportingClusterNames = append(reportingClusterNames, clusterName)
+		}
+		for _, dt := range reports[clusterName].Definitions {
+			if !f.MatchTemplateKind(dt.Kind) {
+				continue
+			}
+			templateDefs[dt.Kind] = append(templateDefs[dt.Kind], definitionInstance{clusterName, dt.Hash, dt.Spec})
+			if constraintDefs[dt.Kind] == nil {
+				constraintDefs[dt.Kind] = make(map[string][]definitionInstance)
+			}
+			for _, dc := range dt.Constraints {
+				if !f.MatchConstraintName(dc.Name) {
+					continue
+				}
+				constraintDefs[dt.Kind][dc.Name] = append(constraintDefs[dt.Kind][dc.Name], definitionInstance{clusterName, dc.Hash, dc.Spec})
+			}
+		}
+	}
+
+	// report all definitions that do not have the same hash everywhere
+	var result []Drift
+	for _, kind := range slices.Sorted(maps.Keys(templateDefs)) {
+		if d, ok := compareDefinitions(templateDefs[kind], reportingClusterNames); ok {
+			d.TemplateKind = kind
+			result = append(result, d)
+		}
+		for _, name := range slices.Sorted(maps.Keys(constraintDefs[kind])) {
+			if d, ok := compareDefinitions(constraintDefs[kind][name], reportingClusterNames); ok {
+				d.TemplateKind = kind
+				d.ConstraintName = name
+				result = append(result, d)
+			}
+		}
+	}
+	return result
+}
+
+// compareDefinitions reports drift between the given instances of the same definition.
+// All clusters in `clusterNames` that do not have an instance of this definition are reported as an absent variant.
+func compareDefinitions(instances []definitionInstance, clusterNames []string) (Drift, bool) {
+	clustersByHash := make(map[string][]string)
+	isPresent := make(map[string]bool, len(instances))
+	for _, inst := range instances {
+		clustersByHash[inst.Hash] = append(clustersByHash[inst.Hash], inst.ClusterName)
+		isPresent[inst.ClusterName] = true
+	}
+	var absentClusters []string
+	for _, clusterName := range clusterNames {
+		if !isPresent[clusterName] {
+			absentClusters = append(absentClusters, clusterName)
+		}
+	}
+	if len(clustersByHash) < 2 && len(absentClusters) == 0 {
+		return Drift{}, false
+	}
+
+	var d Drift
+	for _, hash := range slices.Sorted(maps.Keys(clustersByHash)) {
+		d.Variants = append(d.Variants, DriftVariant{Hash: hash, Clusters: clustersByHash[hash]})
+	}
+	if len(absentClusters) > 0 {
+		d.Variants = append(d.Variants, DriftVariant{Absent: true, Clusters: absentClusters})
+	}
+	if len(clustersByHash) < 2 {
+		// the definition is the same wherever it exists, so there are no differing fields
+		return d, true
+	}
+
+	// a per-field diff can only be computed if all clusters have reported their full specs
+	flattenedSpecs := make(map[string]map[string]any, len(instances))
+	for _, inst := range instances {
+		if inst.Spec == nil {
+			return d, true
+		}
+		flattened := make(map[string]any)
+		flattenSpec("", inst.Spec, flattened)
+		flattenedSpecs[inst.ClusterName] = flattened
+	}
+
+	allPaths := make(map[string]struct{})
+	for _, flattened := range flattenedSpecs {
+		for path := range flattened {
+			allPaths[path] = struct{}{}
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(allPaths)) {
+		values := make(map[string]any, len(instances))
+		isDifferent := false
+		var firstValue []byte
+		for idx, inst := range instances {
+			value, exists := flattenedSpecs[inst.ClusterName][path]
+			values[inst.ClusterName] = value
+			if !exists {
+				isDifferent = true
+				continue
+			}
+			//NOTE: Leaf values only contain strings, numbers, bools and nulls, so their serialization is canonical.
+			buf, _ := json.Marshal(value) //nolint:errcheck,errchkjson // cannot fail for leaf values from a JSON document
+			if idx == 0 {
+				firstValue = buf
+			} else if string(buf) != string(firstValue) {
+				isDifferent = true
+			}
+		}
+		if isDifferent {
+			d.Fields = append(d.Fields, DriftField{Path: path, Values: values})
+		}
+	}
+	return d, true
+}
+
+// flattenSpec converts a nested spec into a flat map from paths to leaf values.
+func flattenSpec(path string, value any, result map[string]any) {
+	switch value := value.(type) {
+	case map[string]any:
+		if len(value) == 0 && path != "" {
+			result[path] = value
+		}
+		for key, subvalue := range value {
+			subpath := key
+			if path != "" {
+				subpath = path + "." + key
+			}
+			flattenSpec(subpath, subvalue, result)
+		}
+	case []any:
+		if len(value) == 0 {
+			result[path] = value
+		}
+		for idx, subvalue := range value {
+			flattenSpec(fmt.Sprintf("%s[%d]", path, idx), subvalue, result)
+		}
+	default:
+		result[path] = value
+	}
+}
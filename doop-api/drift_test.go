@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestDetectDrift(t *testing.T) {
+	makeReport := func(region, templateHash, constraintHash string, constraintSpec map[string]any) doop.Report {
+		return doop.Report{
+			ClusterIdentity: map[string]string{"region": region},
+			Definitions: []doop.DefinitionForTemplate{{
+				Kind: "GkFirstTemplate",
+				Hash: templateHash,
+				Constraints: []doop.DefinitionForConstraint{{
+					Name: "firstconstraint",
+					Hash: constraintHash,
+					Spec: constraintSpec,
+				}},
+			}},
+		}
+	}
+	reports := map[string]doop.Report{
+		"cluster1": makeReport("one", "aaa", "111", map[string]any{"parameters": map[string]any{"limit": 5.0}}),
+		"cluster2": makeReport("one", "aaa", "222", map[string]any{"parameters": map[string]any{"limit": 10.0}}),
+		"cluster3": makeReport("two", "aaa", "111", map[string]any{"parameters": map[string]any{"limit": 5.0}}),
+		"cluster4": makeReport("two", "bbb", "333", map[string]any{"parameters": map[string]any{"limit": 5.0}, "enforcementAction": "deny"}),
+	}
+
+	// without grouping, all clusters are compared with each other
+	actual := DetectDrift(reports, nil, must.ReturnT(BuildFilterSet(url.Values{}))(t))
+	assert.Equal(t, actual, DriftReport{Drifts: []Drift{
+		{
+			Class:        map[string]string{},
+			TemplateKind: "GkFirstTemplate",
+			Variants: []DriftVariant{
+				{Hash: "aaa", Clusters: []string{"cluster1", "cluster2", "cluster3"}},
+				{Hash: "bbb", Clusters: []string{"cluster4"}},
+			},
+		},
+		{
+			Class:          map[string]string{},
+			TemplateKind:   "GkFirstTemplate",
+			ConstraintName: "firstconstraint",
+			Variants: []DriftVariant{
+				{Hash: "111", Clusters: []string{"cluster1", "cluster3"}},
+				{Hash: "222", Clusters: []string{"cluster2"}},
+				{Hash: "333", Clusters: []string{"cluster4"}},
+			},
+			Fields: []DriftField{
+				{Path: "enforcementAction", Values: map[string]any{"cluster1": nil, "cluster2": nil, "cluster3": nil, "cluster4": "deny"}},
+				{Path: "parameters.limit", Values: map[string]any{"cluster1": 5.0, "cluster2": 10.0, "cluster3": 5.0, "cluster4": 5.0}},
+			},
+		},
+	}})
+
+	// with grouping, only clusters within the same region are compared
+	actual = DetectDrift(reports, []string{"region"}, must.ReturnT(BuildFilterSet(url.Values{}))(t))
+	assert.Equal(t, actual, DriftReport{Drifts: []Drift{
+		{
+			Class:          map[string]string{"region": "one"},
+			TemplateKind:   "GkFirstTemplate",
+			ConstraintName: "firstconstraint",
+			Variants: []DriftVariant{
+				{Hash: "111", Clusters: []string{"cluster1"}},
+				{Hash: "222", Clusters: []string{"cluster2"}},
+			},
+			Fields: []DriftField{
+				{Path: "parameters.limit", Values: map[string]any{"cluster1": 5.0, "cluster2": 10.0}},
+			},
+		},
+		{
+			Class:        map[string]string{"region": "two"},
+			TemplateKind: "GkFirstTemplate",
+			Variants: []DriftVariant{
+				{Hash: "aaa", Clusters: []string{"cluster3"}},
+				{Hash: "bbb", Clusters: []string{"cluster4"}},
+			},
+		},
+		{
+			Class:          map[string]string{"region": "two"},
+			TemplateKind:   "GkFirstTemplate",
+			ConstraintName: "firstconstraint",
+			Variants: []DriftVariant{
+				{Hash: "111", Clusters: []string{"cluster3"}},
+				{Hash: "333", Clusters: []string{"cluster4"}},
+			},
+			Fields: []DriftField{
+				{Path: "enforcementAction", Values: map[string]any{"cluster3": nil, "cluster4": "deny"}},
+			},
+		},
+	}})
+
+	// filters restrict which clusters and definitions are compared
+	actual = DetectDrift(reports, nil, must.ReturnT(BuildFilterSet(query("cluster_identity.region=one&constraint_name=nonexistent")))(t))
+	assert.Equal(t, actual, DriftReport{Drifts: []Drift{}})
+}
+
+func TestDetectDriftWithAbsentDefinitions(t *testing.T) {
+	makeReport := func(templateKinds ...string) doop.Report {
+		var report doop.Report
+		for _, kind := range templateKinds {
+			report.Definitions = append(report.Definitions, doop.DefinitionForTemplate{
+				Kind: kind,
+				Hash: "aaa",
+				Spec: map[string]any{"crd": "foo"},
+				Constraints: []doop.DefinitionForConstraint{{
+					Name: strings.ToLower(kind),
+					Hash: "111",
+					Spec: map[string]any{"parameters": map[string]any{"limit": 5.0}},
+				}},
+			})
+		}
+		return report
+	}
+	reports := map[string]doop.Report{
+		"cluster1": makeReport("GkFirstTemplate", "GkSecondTemplate"),
+		"cluster2": makeReport("GkFirstTemplate", "GkSecondTemplate"),
+		"cluster3": makeReport("GkFirstTemplate"),
+		// reports without any definitions (e.g. from older analyzers) are not considered
+		"cluster4": {},
+	}
+
+	// definitions that are identical wherever they exist are still reported if they are missing in some clusters
+	actual := DetectDrift(reports, nil, must.ReturnT(BuildFilterSet(url.Values{}))(t))
+	assert.Equal(t, actual, DriftReport{Drifts: []Drift{
+		{
+			Class:        map[string]string{},
+			TemplateKind: "GkSecondTemplate",
+			Variants: []DriftVariant{
+				{Hash: "aaa", Clusters: []string{"cluster1", "cluster2"}},
+				{Absent: true, Clusters: []string{"cluster3"}},
+			},
+		},
+		{
+			Class:          map[string]string{},
+			TemplateKind:   "GkSecondTemplate",
+			ConstraintName: "gksecondtemplate",
+			Variants: []DriftVariant{
+				{Hash: "111", Clusters: []string{"cluster1", "cluster2"}},
+				{Absent: true, Clusters: []string{"cluster3"}},
+			},
+		},
+	}})
+}
@@ -4,39 +4,86 @@
 package main
 
 import (
+	"fmt"
 	"net/url"
-	"slices"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
 // FilterSet describes which clusters/templates/constraints/violations to filter out when aggregating reports.
 type FilterSet struct {
-	clusterIdentity map[string]filter
+	clusterIdentity map[string]*filter
 	templateKind    filter
 	constraintName  filter
 	severity        filter
-	objectIdentity  map[string]filter
+	objectIdentity  map[string]*filter
+	kind            filter
+	namespace       filter
+	name            filter
+	// lowercased search term for messages (empty if no search was requested)
+	messageSearch string
+	includeStale  bool
 }
 
 // BuildFilterSet collects filter settings from the given URL query.
-func BuildFilterSet(query url.Values) FilterSet {
-	return FilterSet{
-		clusterIdentity: buildMapFilter(query, "cluster_identity."),
-		templateKind:    filter(query["template_kind"]),
-		constraintName:  filter(query["constraint_name"]),
-		severity:        filter(query["severity"]),
-		objectIdentity:  buildMapFilter(query, "object_identity."),
+//
+// Each query key consists of a field name and an optional operator suffix, see parseFilterKey().
+// Query keys that do not refer to a filterable field are ignored.
+func BuildFilterSet(query url.Values) (FilterSet, error) {
+	fs := FilterSet{
+		clusterIdentity: make(map[string]*filter),
+		objectIdentity:  make(map[string]*filter),
 	}
-}
-
-func buildMapFilter(query url.Values, prefix string) map[string]filter {
-	result := make(map[string]filter)
 	for queryKey, values := range query {
-		if key, ok := strings.CutPrefix(queryKey, prefix); ok {
-			result[key] = filter(values)
+		field, op := parseFilterKey(queryKey)
+
+		var target *filter
+		switch field {
+		case "template_kind":
+			target = &fs.templateKind
+		case "constraint_name":
+			target = &fs.constraintName
+		case "severity":
+			target = &fs.severity
+		case "kind":
+			target = &fs.kind
+		case "namespace":
+			target = &fs.namespace
+		case "name":
+			target = &fs.name
+		case "q":
+			if op != (filterOperator{Kind: "="}) || len(values) != 1 {
+				return FilterSet{}, fmt.Errorf("invalid filter %s: expected exactly one value with the \"=\" operator", queryKey)
+			}
+			fs.messageSearch = strings.ToLower(values[0])
+			continue
+		case "include_stale":
+			var err error
+			fs.includeStale, err = strconv.ParseBool(values[0])
+			if err != nil || op != (filterOperator{Kind: "="}) || len(values) != 1 {
+				return FilterSet{}, fmt.Errorf("invalid value for include_stale: %q", values[0])
+			}
+			continue
+		default:
+			if key, ok := strings.CutPrefix(field, "cluster_identity."); ok {
+				target = filterForKey(fs.clusterIdentity, key)
+			} else if key, ok := strings.CutPrefix(field, "object_identity."); ok {
+				target = filterForKey(fs.objectIdentity, key)
+			} else {
+				continue
+			}
+		}
+
+		for _, value := range values {
+			err := target.add(op, value)
+			if err != nil {
+				return FilterSet{}, fmt.Errorf("invalid filter %s: %w", queryKey+"="+value, err)
+			}
 		}
 	}
-	return result
+	return fs, nil
 }
 
 // MatchClusterIdentity checks whether a cluster with the given identity shall be included in the result.
@@ -74,12 +121,115 @@ func (fs FilterSet) MatchSeverity(severity string) bool {
 	return fs.severity.match(severity)
 }
 
-// A list of allowed values for a certain field. If the list is empty, all values are allowed.
-type filter []string
+// MatchStaleness checks whether a cluster with a stale or non-stale report shall be included in the result.
+// Stale reports are excluded unless `include_stale=true` was given.
+func (fs FilterSet) MatchStaleness(isStale bool) bool {
+	return fs.includeStale || !isStale
+}
+
+// HasObjectFilters returns whether MatchObject() can return false at all.
+// This is used to skip the per-instance filtering of violation groups when no such filter was given.
+func (fs FilterSet) HasObjectFilters() bool {
+	return !fs.kind.isEmpty() || !fs.namespace.isEmpty() || !fs.name.isEmpty() || fs.messageSearch != ""
+}
+
+// MatchObject checks whether a violation or admission event for the given object and with the given message shall be included in the result.
+func (fs FilterSet) MatchObject(kind, namespace, name, message string) bool {
+	if !fs.kind.match(kind) || !fs.namespace.match(namespace) || !fs.name.match(name) {
+		return false
+	}
+	return fs.messageSearch == "" || strings.Contains(strings.ToLower(message), fs.messageSearch)
+}
+
+// filterOperator describes how a filter value is compared with the actual value.
+type filterOperator struct {
+	IsNegated bool
+	// One of "=" (equality or regex match), "^=" (prefix match) or "*=" (glob match).
+	Kind string
+}
+
+// parseFilterKey splits a query key like "severity!" (from a query string
+// like "severity!=debug") into the field name and the operator.
+func parseFilterKey(queryKey string) (field string, op filterOperator) {
+	// The query string "foo!^=bar" is parsed by package url into key "foo!^" and value "bar".
+	op.Kind = "="
+	for _, kind := range []string{"^", "*"} {
+		if rest, ok := strings.CutSuffix(queryKey, kind); ok {
+			queryKey = rest
+			op.Kind = kind + "="
+			break
+		}
+	}
+	queryKey, op.IsNegated = strings.CutSuffix(queryKey, "!")
+	return queryKey, op
+}
+
+// A set of allowed and forbidden values for a certain field.
+// The field matches if it matches any of the positive matchers (or if there are none),
+// and none of the negative matchers.
+type filter struct {
+	positive []func(string) bool
+	negative []func(string) bool
+}
+
+func filterForKey(m map[string]*filter, key string) *filter {
+	if m[key] == nil {
+		m[key] = &filter{}
+	}
+	return m[key]
+}
+
+func (f *filter) add(op filterOperator, value string) error {
+	var matcher func(string) bool
+	switch op.Kind {
+	case "^=":
+		matcher = func(s string) bool { return strings.HasPrefix(s, value) }
+	case "*=":
+		_, err := path.Match(value, "")
+		if err != nil {
+			return fmt.Errorf("invalid glob: %w", err)
+		}
+		matcher = func(s string) bool {
+			ok, _ := path.Match(value, s) //nolint:errcheck // the pattern was already validated above
+			return ok
+		}
+	default:
+		if pattern, ok := strings.CutPrefix(value, "~"); ok {
+			rx, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid regex: %w", err)
+			}
+			matcher = rx.MatchString
+		} else {
+			matcher = func(s string) bool { return s == value }
+		}
+	}
+
+	if op.IsNegated {
+		f.negative = append(f.negative, matcher)
+	} else {
+		f.positive = append(f.positive, matcher)
+	}
+	return nil
+}
+
+func (f filter) isEmpty() bool {
+	return len(f.positive) == 0 && len(f.negative) == 0
+}
 
 func (f filter) match(givenValue string) bool {
-	if len(f) == 0 {
+	for _, m := range f.negative {
+		if m(givenValue) {
+			return false
+		}
+	}
+	if len(f.positive) == 0 {
 		return true
 	}
-	return slices.Contains(f, givenValue)
+	for _, m := range f.positive {
+		if m(givenValue) {
+			return true
+		}
+	}
+	return false
 }
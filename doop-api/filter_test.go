@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+)
+
+func TestFilterOperators(t *testing.T) {
+	testCases := []struct {
+		Query    string
+		Value    string
+		Expected bool
+	}{
+		// exact match (existing behavior)
+		{"severity=error", "error", true},
+		{"severity=error", "warning", false},
+		{"severity=error&severity=warning", "warning", true},
+		// negation
+		{"severity!=debug", "error", true},
+		{"severity!=debug", "debug", false},
+		{"severity!=debug&severity!=info", "info", false},
+		// regex
+		{"severity=~^err", "error", true},
+		{"severity=~^err", "warning", false},
+		{"severity!=~^err", "error", false},
+		{"severity!=~^err", "warning", true},
+		// prefix
+		{"severity^=err", "error", true},
+		{"severity^=err", "terror", false},
+		{"severity!^=err", "error", false},
+		// glob
+		{"severity*=*ror", "error", true},
+		{"severity*=*ror", "warning", false},
+		{"severity!*=*ror", "error", false},
+		// positive and negative filters combined
+		{"severity=~r&severity!=error", "error", false},
+		{"severity=~r&severity!=error", "warning", true},
+	}
+
+	for _, tc := range testCases {
+		fs := must.ReturnT(BuildFilterSet(query(tc.Query)))(t)
+		assert.Equal(t, fs.MatchSeverity(tc.Value), tc.Expected)
+	}
+}
+
+func TestFilterOnIdentities(t *testing.T) {
+	fs := must.ReturnT(BuildFilterSet(query("cluster_identity.region^=eu-&object_identity.namespace!*=kube-*")))(t)
+
+	assert.Equal(t, fs.MatchClusterIdentity(map[string]string{"region": "eu-de-1"}), true)
+	assert.Equal(t, fs.MatchClusterIdentity(map[string]string{"region": "na-us-1"}), false)
+	assert.Equal(t, fs.MatchClusterIdentity(map[string]string{}), false)
+
+	assert.Equal(t, fs.MatchObjectIdentity(map[string]string{"namespace": "monsoon3"}), true)
+	assert.Equal(t, fs.MatchObjectIdentity(map[string]string{"namespace": "kube-system"}), false)
+	assert.Equal(t, fs.MatchObjectIdentity(map[string]string{}), true)
+}
+
+func TestInvalidFilters(t *testing.T) {
+	for _, queryStr := range []string{"constraint_name=~(foo", "template_kind*=[abc"} {
+		_, err := BuildFilterSet(query(queryStr))
+		if err == nil {
+			t.Errorf("expected error for %q, but got none", queryStr)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ViolationsFormat is the output format of GET /v2/violations.
+type ViolationsFormat string
+
+const (
+	// ViolationsFormatJSON is the default output format: a doop.AggregatedReport.
+	ViolationsFormatJSON ViolationsFormat = "json"
+	// ViolationsFormatCSV is a flat table with one row per violation.
+	ViolationsFormatCSV ViolationsFormat = "csv"
+	// ViolationsFormatSARIF is a SARIF 2.1.0 log with one result per violation.
+	ViolationsFormatSARIF ViolationsFormat = "sarif"
+)
+
+// ContentType returns the value for the Content-Type header of responses in this format.
+func (f ViolationsFormat) ContentType() string {
+	switch f {
+	case ViolationsFormatCSV:
+		return "text/csv; charset=utf-8"
+	case ViolationsFormatSARIF:
+		return "application/sarif+json"
+	default:
+		return "application/json"
+	}
+}
+
+// ParseViolationsFormat chooses the output format for GET /v2/violations.
+// The `format` query argument takes precedence over the Accept header.
+func ParseViolationsFormat(r *http.Request) (ViolationsFormat, error) {
+	if value := r.URL.Query().Get("format"); value != "" {
+		switch f := ViolationsFormat(value); f {
+		case ViolationsFormatJSON, ViolationsFormatCSV, ViolationsFormatSARIF:
+			return f, nil
+		default:
+			return "", fmt.Errorf("invalid value for format: %q (expected \"json\", \"csv\" or \"sarif\")", value)
+		}
+	}
+
+	// the first media type that we understand wins; quality values are not considered
+	for accepted := range strings.SplitSeq(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return ViolationsFormatCSV, nil
+		case "application/sarif+json":
+			return ViolationsFormatSARIF, nil
+		case "application/json":
+			return ViolationsFormatJSON, nil
+		}
+	}
+	return ViolationsFormatJSON, nil
+}
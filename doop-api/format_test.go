@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+)
+
+func TestParseViolationsFormat(t *testing.T) {
+	testCases := []struct {
+		Query    string
+		Accept   string
+		Expected ViolationsFormat
+	}{
+		{"", "", ViolationsFormatJSON},
+		{"", "*/*", ViolationsFormatJSON},
+		{"", "text/csv", ViolationsFormatCSV},
+		{"", "text/html, text/csv;q=0.9, */*;q=0.8", ViolationsFormatCSV},
+		{"", "application/json, text/csv", ViolationsFormatJSON},
+		{"format=json", "text/csv", ViolationsFormatJSON},
+		{"format=csv", "application/json", ViolationsFormatCSV},
+		{"", "application/sarif+json", ViolationsFormatSARIF},
+		{"format=sarif", "", ViolationsFormatSARIF},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest("GET", "/v2/violations?"+tc.Query, nil)
+		if tc.Accept != "" {
+			r.Header.Set("Accept", tc.Accept)
+		}
+		assert.Equal(t, must.ReturnT(ParseViolationsFormat(r))(t), tc.Expected)
+	}
+
+	r := httptest.NewRequest("GET", "/v2/violations?format=xml", nil)
+	_, err := ParseViolationsFormat(r)
+	if err == nil {
+		t.Error("expected error for format=xml, but got none")
+	}
+}
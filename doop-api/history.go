@@ -0,0 +1,475 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sapcc/go-bits/logg"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+const (
+	// Within this time, all recorded samples are kept.
+	historyRawRetention = 48 * time.Hour
+	// Within this time, one sample per hour is kept. After that, one sample per day is kept until the retention ends.
+	historyHourlyRetention = 30 * 24 * time.Hour
+	// The format of the file names in the HistoryStore directory.
+	historyFileDateFormat = "2006-01-02"
+)
+
+// HistoryStore records summary counts of the current reports in a local directory, for use by GET /v2/history.
+//
+// There is one file for each day (in UTC), containing one historySample per line in JSON format.
+// As files get older, they are downsampled and eventually deleted by Compact().
+type HistoryStore struct {
+	path string
+	// Only these keys from object_identity are recorded.
+	objectIdentityKeys []string
+	// Samples older than this are deleted.
+	retention time.Duration
+	// Serializes all file accesses.
+	mutex sync.Mutex
+}
+
+// historySample is the data that HistoryStore records at one point in time.
+type historySample struct {
+	Time time.Time `json:"t"`
+	// Needed for evaluating cluster_identity filters in GET /v2/history.
+	ClusterIdentities map[string]map[string]string `json:"cluster_identities"`
+	Counts            []historyCount               `json:"counts"`
+}
+
+// historyCount appears in type historySample.
+type historyCount struct {
+	ClusterName    string            `json:"cluster"`
+	TemplateKind   string            `json:"template_kind"`
+	ConstraintName string            `json:"constraint_name"`
+	Severity       string            `json:"severity,omitempty"`
+	ObjectIdentity map[string]string `json:"object_identity,omitempty"`
+	// Violation groups are counted within each cluster's report, i.e. before aggregation across clusters.
+	Violations      int `json:"violations"`
+	ViolationGroups int `json:"violation_groups"`
+}
+
+// NewHistoryStore creates a HistoryStore in the given directory, which is created if it does not exist yet.
+func NewHistoryStore(path string, objectIdentityKeys []string, retention time.Duration) (*HistoryStore, error) {
+	err := os.MkdirAll(path, 0o777)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create history directory: %w", err)
+	}
+	return &HistoryStore{
+		path:               path,
+		objectIdentityKeys: objectIdentityKeys,
+		retention:          retention,
+	}, nil
+}
+
+// Run records a sample of the downloader's current reports at the given interval, until `ctx` expires.
+// Old samples are downsampled or deleted after each recording.
+func (h *HistoryStore) Run(ctx context.Context, downloader *Downloader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		reports, _ := downloader.GetReports()
+		now := time.Now()
+		err := h.Record(h.takeSample(reports, now))
+		if err != nil {
+			logg.Error("could not record history sample: %s", err.Error())
+		}
+		err = h.Compact(now)
+		if err != nil {
+			logg.Error("could not compact history: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// takeSample counts the violations in the given reports. Stale reports are not counted.
+func (h *HistoryStore) takeSample(reports map[string]doop.Report, now time.Time) historySample {
+	type countKey struct {
+		TemplateKind   string
+		ConstraintName string
+		Severity       string
+		// from historyObjectIdentityKey()
+		ObjectIdentity string
+	}
+
+	sample := historySample{
+		Time:              now.UTC(),
+		ClusterIdentities: make(map[string]map[string]string, len(reports)),
+	}
+	for _, clusterName := range slices.Sorted(maps.Keys(reports)) {
+		report := reports[clusterName]
+		if report.IsStale {
+			continue
+		}
+		sample.ClusterIdentities[clusterName] = report.ClusterIdentity
+
+		countIndex := make(map[countKey]int)
+		for _, rt := range report.Templates {
+			for _, rc := range rt.Constraints {
+				for _, vg := range rc.ViolationGroups {
+					// instances may have a different object identity than the group pattern (e.g. when merging rules
+					// collapse objects from different namespaces), so each instance is counted under its own object identity;
+					// the group is counted once for each object identity that it contributes to
+					touched := make(map[int]bool)
+					for _, instance := range vg.Instances {
+						v := instance.ExpandedFrom(vg.Pattern)
+						oid := make(map[string]string, len(h.objectIdentityKeys))
+						for _, key := range h.objectIdentityKeys {
+							if value, exists := v.ObjectIdentity[key]; exists {
+								oid[key] = value
+							}
+						}
+						key := countKey{rt.Kind, rc.Name, rc.Metadata.Severity, historyObjectIdentityKey(oid)}
+						idx, exists := countIndex[key]
+						if !exists {
+							idx = len(sample.Counts)
+							countIndex[key] = idx
+							sample.Counts = append(sample.Counts, historyCount{
+								ClusterName:    clusterName,
+								TemplateKind:   rt.Kind,
+								ConstraintName: rc.Name,
+								Severity:       rc.Metadata.Severity,
+								ObjectIdentity: oid,
+							})
+						}
+						sample.Counts[idx].Violations++
+						if !touched[idx] {
+							touched[idx] = true
+							sample.Counts[idx].ViolationGroups++
+						}
+					}
+				}
+			}
+		}
+	}
+	return sample
+}
+
+// historyObjectIdentityKey serializes a set of object identity values into a string that can be used as a map key.
+func historyObjectIdentityKey(oid map[string]string) string {
+	var sb strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(oid)) {
+		fmt.Fprintf(&sb, "%q=%q,", key, oid[key])
+	}
+	return sb.String()
+}
+
+func (h *HistoryStore) filePath(day time.Time) string {
+	return filepath.Join(h.path, day.UTC().Format(historyFileDateFormat)+".jsonl")
+}
+
+// Record appends the given sample to the file for its day.
+func (h *HistoryStore) Record(sample historySample) error {
+	buf, err := json.Marshal(sample)
+	if err != nil {
+		return fmt.Errorf("cannot encode history sample: %w", err)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	path := h.filePath(sample.Time)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o666)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(buf, '\n'))
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// listDays returns the days for which history files exist, in chronological order.
+// The caller must hold h.mutex.
+func (h *HistoryStore) listDays() ([]time.Time, error) {
+	entries, err := os.ReadDir(h.path)
+	if err != nil {
+		return nil, err
+	}
+	var result []time.Time
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		day, err := time.Parse(historyFileDateFormat, name)
+		if err != nil {
+			continue // not one of our files
+		}
+		result = append(result, day)
+	}
+	slices.SortFunc(result, time.Time.Compare)
+	return result, nil
+}
+
+// readDay reads all samples from the file for the given day.
+// The caller must hold h.mutex.
+func (h *HistoryStore) readDay(day time.Time) ([]historySample, error) {
+	path := h.filePath(day)
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var result []historySample
+	for line := range bytes.Lines(buf) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var sample historySample
+		err := json.Unmarshal(line, &sample)
+		if err != nil {
+			// a partially written line (e.g. from a crash during Record) should not make the whole day unreadable
+			logg.Error("skipping unreadable history sample in %s: %s", path, err.Error())
+			continue
+		}
+		result = append(result, sample)
+	}
+	return result, nil
+}
+
+// Compact downsamples old history files and deletes those that are past the retention period.
+func (h *HistoryStore) Compact(now time.Time) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	days, err := h.listDays()
+	if err != nil {
+		return err
+	}
+	for _, day := range days {
+		// a day is only considered for downsampling once all of it is older than the respective threshold
+		age := now.Sub(day.AddDate(0, 0, 1))
+		var bucketSize time.Duration
+		switch {
+		case age > h.retention:
+			err := os.Remove(h.filePath(day))
+			if err != nil {
+				return err
+			}
+			continue
+		case age > historyHourlyRetention:
+			bucketSize = 24 * time.Hour
+		case age > historyRawRetention:
+			bucketSize = time.Hour
+		default:
+			continue
+		}
+
+		samples, err := h.readDay(day)
+		if err != nil {
+			return err
+		}
+		downsampled := downsampleHistory(samples, bucketSize)
+		if len(downsampled) == len(samples) {
+			continue // nothing to do (usually because this file was already downsampled before)
+		}
+		err = h.writeDay(day, downsampled)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downsampleHistory keeps only the last sample in each bucket of the given size.
+func downsampleHistory(samples []historySample, bucketSize time.Duration) []historySample {
+	var result []historySample
+	for _, sample := range samples {
+		if len(result) > 0 && result[len(result)-1].Time.Truncate(bucketSize).Equal(sample.Time.Truncate(bucketSize)) {
+			result[len(result)-1] = sample
+		} else {
+			result = append(result, sample)
+		}
+	}
+	return result
+}
+
+// writeDay atomically replaces the file for the given day.
+// The caller must hold h.mutex.
+func (h *HistoryStore) writeDay(day time.Time, samples []historySample) error {
+	var buf bytes.Buffer
+	for _, sample := range samples {
+		line, err := json.Marshal(sample)
+		if err != nil {
+			return fmt.Errorf("cannot encode history sample: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	path := h.filePath(day)
+	tmpPath := path + ".tmp"
+	err := os.WriteFile(tmpPath, buf.Bytes(), 0o666)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// ReadSamples returns all samples recorded within the given time range (inclusive), in chronological order.
+func (h *HistoryStore) ReadSamples(from, to time.Time) ([]historySample, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	days, err := h.listDays()
+	if err != nil {
+		return nil, err
+	}
+	var result []historySample
+	for _, day := range days {
+		if day.AddDate(0, 0, 1).Before(from) || day.After(to) {
+			continue
+		}
+		samples, err := h.readDay(day)
+		if err != nil {
+			return nil, err
+		}
+		for _, sample := range samples {
+			if !sample.Time.Before(from) && !sample.Time.After(to) {
+				result = append(result, sample)
+			}
+		}
+	}
+	slices.SortStableFunc(result, func(lhs, rhs historySample) int {
+		return lhs.Time.Compare(rhs.Time)
+	})
+	return result, nil
+}
+
+// HistoryResult is the data structure that is returned by GET /v2/history.
+type HistoryResult struct {
+	Series []HistorySeries `json:"series"`
+}
+
+// HistorySeries appears in type HistoryResult.
+type HistorySeries struct {
+	// Only contains the fields that were requested in the `group_by` query argument.
+	Labels map[string]string `json:"labels"`
+	Points []HistoryPoint    `json:"points"`
+}
+
+// HistoryPoint appears in type HistorySeries.
+type HistoryPoint struct {
+	Time            time.Time `json:"time"`
+	Violations      int       `json:"violations"`
+	ViolationGroups int       `json:"violation_groups"`
+}
+
+// ParseHistoryGroupKeys collects the fields from the `group_by` query argument of GET /v2/history.
+func (h *HistoryStore) ParseHistoryGroupKeys(groupBy []string) ([]string, error) {
+	result := make([]string, 0, len(groupBy))
+	for _, value := range groupBy {
+		switch value {
+		case "cluster", "template_kind", "constraint_name", "severity":
+		default:
+			key, ok := strings.CutPrefix(value, "object_identity.")
+			if !ok || !slices.Contains(h.objectIdentityKeys, key) {
+				return nil, fmt.Errorf("invalid value for group_by: %q (expected \"cluster\", \"template_kind\", \"constraint_name\", \"severity\" or \"object_identity.$KEY\" for a recorded $KEY)", value)
+			}
+		}
+		result = append(result, value)
+	}
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
+
+// CheckFilterSet returns an error if the given FilterSet uses filters that cannot be evaluated on recorded samples.
+func (h *HistoryStore) CheckFilterSet(f FilterSet) error {
+	if f.HasObjectFilters() {
+		return errors.New("filters on individual objects (kind, namespace, name, q) are not supported for history")
+	}
+	for key := range f.objectIdentity {
+		if !slices.Contains(h.objectIdentityKeys, key) {
+			return fmt.Errorf("cannot filter history by object_identity.%s since this key is not recorded", key)
+		}
+	}
+	return nil
+}
+
+// BuildHistoryResult sums up the counts in the given samples into time series, one for each combination of values for the given group keys.
+// Every series has one point for each sample, so that times where a series has no violations are shown as zero.
+func BuildHistoryResult(samples []historySample, groupKeys []string, f FilterSet) HistoryResult {
+	type seriesState struct {
+		Labels map[string]string
+		// same indexes as `samples`
+		Points []HistoryPoint
+	}
+	seriesByKey := make(map[string]*seriesState)
+
+	for sampleIdx, sample := range samples {
+		for _, c := range sample.Counts {
+			if !f.MatchClusterIdentity(sample.ClusterIdentities[c.ClusterName]) ||
+				!f.MatchTemplateKind(c.TemplateKind) ||
+				!f.MatchConstraintName(c.ConstraintName) ||
+				!f.MatchSeverity(c.Severity) ||
+				!f.MatchObjectIdentity(c.ObjectIdentity) {
+				continue
+			}
+
+			labels := make(map[string]string, len(groupKeys))
+			for _, key := range groupKeys {
+				switch key {
+				case "cluster":
+					labels[key] = c.ClusterName
+				case "template_kind":
+					labels[key] = c.TemplateKind
+				case "constraint_name":
+					labels[key] = c.ConstraintName
+				case "severity":
+					labels[key] = c.Severity
+				default:
+					labels[key] = c.ObjectIdentity[strings.TrimPrefix(key, "object_identity.")]
+				}
+			}
+			seriesKey := historyObjectIdentityKey(labels)
+
+			series := seriesByKey[seriesKey]
+			if series == nil {
+				series = &seriesState{Labels: labels, Points: make([]HistoryPoint, len(samples))}
+				for idx, s := range samples {
+					series.Points[idx].Time = s.Time
+				}
+				seriesByKey[seriesKey] = series
+			}
+			series.Points[sampleIdx].Violations += c.Violations
+			series.Points[sampleIdx].ViolationGroups += c.ViolationGroups
+		}
+	}
+
+	result := HistoryResult{Series: make([]HistorySeries, 0, len(seriesByKey))}
+	for _, series := range seriesByKey {
+		result.Series = append(result.Series, HistorySeries{Labels: series.Labels, Points: series.Points})
+	}
+	slices.SortFunc(result.Series, func(lhs, rhs HistorySeries) int {
+		return compareStringMapsByKeys(lhs.Labels, rhs.Labels, groupKeys)
+	})
+	return result
+}
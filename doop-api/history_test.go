@@ -0,0 +1,225 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestHistoryTakeSample(t *testing.T) {
+	h := must.ReturnT(NewHistoryStore(t.TempDir(), []string{"type"}, 365*24*time.Hour))(t)
+	reports := map[string]doop.Report{
+		"cluster1": mustParseJSON[doop.Report](t, "fixtures/input-cluster1.json").SetClusterName("cluster1"),
+		"cluster3": mustParseJSON[doop.Report](t, "fixtures/input-cluster3.json").SetClusterName("cluster3"),
+		"cluster4": mustParseJSON[doop.Report](t, "fixtures/input-cluster4.json").SetClusterName("cluster4"),
+	}
+	// stale reports are not recorded
+	stale := reports["cluster4"]
+	stale.IsStale = true
+	reports["cluster4"] = stale
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	sample := h.takeSample(reports, now)
+	assert.Equal(t, sample, historySample{
+		Time: now,
+		ClusterIdentities: map[string]map[string]string{
+			"cluster1": reports["cluster1"].ClusterIdentity,
+			"cluster3": reports["cluster3"].ClusterIdentity,
+		},
+		Counts: []historyCount{
+			{
+				ClusterName:     "cluster1",
+				TemplateKind:    "GkFirstTemplate",
+				ConstraintName:  "firstconstraint",
+				Severity:        "info",
+				ObjectIdentity:  map[string]string{"type": "production"},
+				Violations:      1,
+				ViolationGroups: 1,
+			},
+			{
+				ClusterName:     "cluster3",
+				TemplateKind:    "GkFirstTemplate",
+				ConstraintName:  "firstconstraint",
+				Severity:        "info",
+				ObjectIdentity:  map[string]string{"type": "production"},
+				Violations:      1,
+				ViolationGroups: 1,
+			},
+		},
+	})
+}
+
+func TestHistoryTakeSampleWithMergedGroup(t *testing.T) {
+	// when merging rules collapse objects with different object identities into one group,
+	// each instance is counted under its own object identity, not under that of the group pattern
+	h := must.ReturnT(NewHistoryStore(t.TempDir(), []string{"type"}, 365*24*time.Hour))(t)
+	report := doop.Report{
+		ClusterIdentity: map[string]string{"name": "cluster1"},
+		Templates: []doop.ReportForTemplate{{
+			Kind: "GkImageTag",
+			Constraints: []doop.ReportForConstraint{{
+				Name:     "imagetag",
+				Metadata: doop.MetadataForConstraint{Severity: "error"},
+				ViolationGroups: []doop.ViolationGroup{{
+					Pattern: doop.Violation{Kind: "Pod", Message: "image uses latest tag", ObjectIdentity: map[string]string{"type": "production"}},
+					Instances: []doop.Violation{
+						{Namespace: "foo", Name: "foo-1"},
+						{Namespace: "foo", Name: "foo-2"},
+						{Namespace: "bar", Name: "bar-1", ObjectIdentity: map[string]string{"type": "staging"}},
+					},
+				}},
+			}},
+		}},
+	}
+	reports := map[string]doop.Report{"cluster1": report.SetClusterName("cluster1")}
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	sample := h.takeSample(reports, now)
+	assert.Equal(t, sample.Counts, []historyCount{
+		{
+			ClusterName:     "cluster1",
+			TemplateKind:    "GkImageTag",
+			ConstraintName:  "imagetag",
+			Severity:        "error",
+			ObjectIdentity:  map[string]string{"type": "production"},
+			Violations:      2,
+			ViolationGroups: 1,
+		},
+		{
+			ClusterName:     "cluster1",
+			TemplateKind:    "GkImageTag",
+			ConstraintName:  "imagetag",
+			Severity:        "error",
+			ObjectIdentity:  map[string]string{"type": "staging"},
+			Violations:      1,
+			ViolationGroups: 1,
+		},
+	})
+}
+
+func makeHistorySample(ts time.Time, counts map[string]int) historySample {
+	sample := historySample{
+		Time:              ts,
+		ClusterIdentities: make(map[string]map[string]string),
+	}
+	for clusterName, count := range counts {
+		sample.ClusterIdentities[clusterName] = map[string]string{"name": clusterName}
+		sample.Counts = append(sample.Counts,
+			historyCount{ClusterName: clusterName, TemplateKind: "GkFoo", ConstraintName: "foo", Severity: "error", Violations: count, ViolationGroups: 1},
+			historyCount{ClusterName: clusterName, TemplateKind: "GkBar", ConstraintName: "bar", Severity: "info", Violations: 1, ViolationGroups: 1},
+		)
+	}
+	return sample
+}
+
+func TestHistoryStoreRecordAndQuery(t *testing.T) {
+	h := must.ReturnT(NewHistoryStore(t.TempDir(), nil, 365*24*time.Hour))(t)
+	start := time.Date(2026, 10, 1, 23, 0, 0, 0, time.UTC)
+	for idx := range 4 {
+		// samples span across midnight, and thus across two files
+		ts := start.Add(time.Duration(idx) * 30 * time.Minute)
+		must.SucceedT(t, h.Record(makeHistorySample(ts, map[string]int{"cluster1": 10 - idx, "cluster2": 5})))
+	}
+
+	// only samples within the time range are returned
+	samples := must.ReturnT(h.ReadSamples(start.Add(30*time.Minute), start.Add(90*time.Minute)))(t)
+	assert.Equal(t, len(samples), 3)
+	assert.Equal(t, samples[0].Time, start.Add(30*time.Minute))
+	assert.Equal(t, samples[2].Time, start.Add(90*time.Minute))
+
+	// without grouping, there is a single series with totals
+	result := BuildHistoryResult(samples, nil, must.ReturnT(BuildFilterSet(query("severity=error")))(t))
+	assert.Equal(t, result, HistoryResult{Series: []HistorySeries{{
+		Labels: map[string]string{},
+		Points: []HistoryPoint{
+			{Time: start.Add(30 * time.Minute), Violations: 9 + 5, ViolationGroups: 2},
+			{Time: start.Add(60 * time.Minute), Violations: 8 + 5, ViolationGroups: 2},
+			{Time: start.Add(90 * time.Minute), Violations: 7 + 5, ViolationGroups: 2},
+		},
+	}}})
+
+	// with grouping, there is one series per group; cluster identity filters work on recorded identities
+	result = BuildHistoryResult(samples[:1], []string{"cluster", "severity"}, must.ReturnT(BuildFilterSet(query("cluster_identity.name!=cluster2")))(t))
+	assert.Equal(t, result, HistoryResult{Series: []HistorySeries{
+		{
+			Labels: map[string]string{"cluster": "cluster1", "severity": "error"},
+			Points: []HistoryPoint{{Time: start.Add(30 * time.Minute), Violations: 9, ViolationGroups: 1}},
+		},
+		{
+			Labels: map[string]string{"cluster": "cluster1", "severity": "info"},
+			Points: []HistoryPoint{{Time: start.Add(30 * time.Minute), Violations: 1, ViolationGroups: 1}},
+		},
+	}})
+}
+
+func TestHistoryStoreCompact(t *testing.T) {
+	dir := t.TempDir()
+	h := must.ReturnT(NewHistoryStore(dir, nil, 90*24*time.Hour))(t)
+
+	// record a sample every 15 minutes on some days in the past
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	days := []time.Time{
+		now.AddDate(0, 0, -1).Truncate(24 * time.Hour),   // recent: kept as is
+		now.AddDate(0, 0, -5).Truncate(24 * time.Hour),   // downsampled to one sample per hour
+		now.AddDate(0, 0, -40).Truncate(24 * time.Hour),  // downsampled to one sample per day
+		now.AddDate(0, 0, -100).Truncate(24 * time.Hour), // deleted
+	}
+	for _, day := range days {
+		for idx := range 96 {
+			ts := day.Add(time.Duration(idx) * 15 * time.Minute)
+			must.SucceedT(t, h.Record(makeHistorySample(ts, map[string]int{"cluster1": idx})))
+		}
+	}
+
+	for range 2 { // the second run should not change anything
+		must.SucceedT(t, h.Compact(now))
+
+		countSamples := func(day time.Time) int {
+			return len(must.ReturnT(h.ReadSamples(day, day.Add(24*time.Hour-time.Nanosecond)))(t))
+		}
+		assert.Equal(t, countSamples(days[0]), 96)
+		assert.Equal(t, countSamples(days[1]), 24)
+		assert.Equal(t, countSamples(days[2]), 1)
+		assert.Equal(t, countSamples(days[3]), 0)
+	}
+
+	// the last sample within each bucket is kept
+	samples := must.ReturnT(h.ReadSamples(days[2], days[2].Add(24*time.Hour)))(t)
+	assert.Equal(t, samples[0].Time, days[2].Add(23*time.Hour+45*time.Minute))
+	assert.Equal(t, samples[0].Counts[0].Violations, 95)
+
+	_, err := os.Stat(filepath.Join(dir, days[3].Format(historyFileDateFormat)+".jsonl"))
+	if !os.IsNotExist(err) {
+		t.Errorf("expected file for %s to be deleted, but got err = %v", days[3].Format(historyFileDateFormat), err)
+	}
+}
+
+func TestHistoryQueryValidation(t *testing.T) {
+	h := must.ReturnT(NewHistoryStore(t.TempDir(), []string{"service"}, 365*24*time.Hour))(t)
+
+	actual := must.ReturnT(h.ParseHistoryGroupKeys([]string{"severity", "object_identity.service", "cluster", "severity"}))(t)
+	assert.Equal(t, actual, []string{"cluster", "object_identity.service", "severity"})
+	for _, value := range []string{"object_identity.team", "namespace"} {
+		_, err := h.ParseHistoryGroupKeys([]string{value})
+		if err == nil {
+			t.Errorf("expected error for group_by=%s, but got none", value)
+		}
+	}
+
+	must.SucceedT(t, h.CheckFilterSet(must.ReturnT(BuildFilterSet(query("object_identity.service=dns&severity=error")))(t)))
+	for _, queryStr := range []string{"object_identity.team=foo", "namespace=kube-system", "q=latest"} {
+		err := h.CheckFilterSet(must.ReturnT(BuildFilterSet(query(queryStr)))(t))
+		if err == nil {
+			t.Errorf("expected error for %s, but got none", queryStr)
+		}
+	}
+}
@@ -6,6 +6,9 @@ package main
 import (
 	"context"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gophercloud/gophercloud/v2/openstack"
@@ -42,12 +45,48 @@ func main() {
 	account := must.Return(gopherschwift.Wrap(client, nil))
 	containerName := osext.MustGetenv("DOOP_API_SWIFT_CONTAINER")
 	container := must.Return(account.Container(containerName).EnsureExists(ctx))
-	downloader := NewDownloader(container)
+	var stalenessThreshold time.Duration
+	if value := os.Getenv("DOOP_API_STALENESS_THRESHOLD"); value != "" {
+		stalenessThreshold = must.Return(time.ParseDuration(value))
+	}
+	refreshInterval := must.Return(time.ParseDuration(osext.GetenvOrDefault("DOOP_API_REFRESH_INTERVAL", "1m")))
+	if refreshInterval <= 0 {
+		logg.Fatal("invalid value for DOOP_API_REFRESH_INTERVAL: %q (must be greater than zero)", refreshInterval.String())
+	}
+	downloadConcurrency := must.Return(strconv.Atoi(osext.GetenvOrDefault("DOOP_API_DOWNLOAD_CONCURRENCY", "8")))
+	if downloadConcurrency <= 0 {
+		logg.Fatal("invalid value for DOOP_API_DOWNLOAD_CONCURRENCY: %d (must be greater than zero)", downloadConcurrency)
+	}
+	downloader := NewDownloader(container, stalenessThreshold, downloadConcurrency)
+
+	// the initial listing must succeed, otherwise we would serve no reports at all until the next refresh;
+	// individual reports that cannot be downloaded are only logged, just like during later refreshes
+	downloadErr, err := downloader.Refresh(ctx)
+	must.Succeed(err)
+	downloader.logRefreshErrors(downloadErr, nil)
+	go downloader.Run(ctx, refreshInterval)
+
+	// history recording is optional since it is the only thing that requires persistent storage
+	var history *HistoryStore
+	if historyPath := os.Getenv("DOOP_API_HISTORY_PATH"); historyPath != "" {
+		historyKeys := strings.Fields(os.Getenv("DOOP_API_HISTORY_OBJECT_IDENTITY_KEYS"))
+		historyRetention := must.Return(time.ParseDuration(osext.GetenvOrDefault("DOOP_API_HISTORY_RETENTION", "8760h")))
+		historyInterval := must.Return(time.ParseDuration(osext.GetenvOrDefault("DOOP_API_HISTORY_INTERVAL", "15m")))
+		history = must.Return(NewHistoryStore(historyPath, historyKeys, historyRetention))
+		go history.Run(ctx, downloader, historyInterval)
+	}
+
+	// violation tracking is optional since it requires write access to the Swift container
+	var tracker *ViolationTracker
+	if osext.GetenvBool("DOOP_API_TRACK_VIOLATIONS") {
+		tracker = NewViolationTracker(container)
+		go tracker.Run(ctx, downloader, refreshInterval)
+	}
 
 	// collect HTTP handlers
 	prometheus.MustRegister(NewMetricCollector(downloader))
 	handler := httpapi.Compose(
-		API{downloader},
+		NewAPI(downloader, history, tracker),
 		httpapi.HealthCheckAPI{SkipRequestLog: true},
 		pprofapi.API{IsAuthorized: pprofapi.IsRequestFromLocalhost},
 	)
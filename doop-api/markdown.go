@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// renderMarkdown renders the Markdown in constraint docstrings into HTML for the UI.
+//
+// Since docstrings are usually short, this only supports a small subset of Markdown:
+// paragraphs, headings, bulleted and numbered lists, fenced code blocks, code spans,
+// strong and emphasized text, and links. Everything else is shown as text.
+// Raw HTML in the input is always escaped, so the result is safe to embed.
+func renderMarkdown(input string) template.HTML {
+	var (
+		sb        strings.Builder
+		paragraph []string
+		listTag   string // "ul" or "ol" while inside a list, "" otherwise
+		listItem  []string
+		codeBlock []string
+		inCode    bool
+	)
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			sb.WriteString("<p>")
+			writeMarkdownInline(&sb, strings.Join(paragraph, " "))
+			sb.WriteString("</p>\n")
+			paragraph = nil
+		}
+	}
+	flushListItem := func() {
+		if len(listItem) > 0 {
+			sb.WriteString("<li>")
+			writeMarkdownInline(&sb, strings.Join(listItem, " "))
+			sb.WriteString("</li>\n")
+			listItem = nil
+		}
+	}
+	closeList := func() {
+		flushListItem()
+		if listTag != "" {
+			sb.WriteString("</" + listTag + ">\n")
+			listTag = ""
+		}
+	}
+	openList := func(tag string) {
+		if listTag != tag {
+			closeList()
+			sb.WriteString("<" + tag + ">\n")
+			listTag = tag
+		}
+		flushListItem()
+	}
+
+	for line := range strings.SplitSeq(strings.ReplaceAll(input, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		// fenced code blocks are shown verbatim
+		if inCode {
+			if strings.HasPrefix(trimmed, "```") {
+				sb.WriteString("<pre><code>" + html.EscapeString(strings.Join(codeBlock, "\n")) + "</code></pre>\n")
+				codeBlock = nil
+				inCode = false
+			} else {
+				codeBlock = append(codeBlock, line)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") {
+			flushParagraph()
+			closeList()
+			inCode = true
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flushParagraph()
+			closeList()
+		case markdownHeadingRx.MatchString(trimmed):
+			flushParagraph()
+			closeList()
+			match := markdownHeadingRx.FindStringSubmatch(trimmed)
+			// headings are demoted since docstrings are shown deep within the page structure
+			tag := "h" + strconv.Itoa(min(len(match[1])+3, 6))
+			sb.WriteString("<" + tag + ">")
+			writeMarkdownInline(&sb, match[2])
+			sb.WriteString("</" + tag + ">\n")
+		case markdownBulletRx.MatchString(line):
+			flushParagraph()
+			openList("ul")
+			listItem = []string{markdownBulletRx.ReplaceAllString(line, "")}
+		case markdownNumberRx.MatchString(line):
+			flushParagraph()
+			openList("ol")
+			listItem = []string{markdownNumberRx.ReplaceAllString(line, "")}
+		case listTag != "":
+			// continuation line of the current list item
+			listItem = append(listItem, trimmed)
+		default:
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+
+	// an unterminated code block extends until the end of the input
+	if inCode {
+		sb.WriteString("<pre><code>" + html.EscapeString(strings.Join(codeBlock, "\n")) + "</code></pre>\n")
+	}
+	flushParagraph()
+	closeList()
+	return template.HTML(sb.String()) //nolint:gosec // all input is escaped above
+}
+
+var (
+	markdownHeadingRx = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	markdownBulletRx  = regexp.MustCompile(`^\s{0,3}[-*+]\s+`)
+	markdownNumberRx  = regexp.MustCompile(`^\s{0,3}[0-9]+[.)]\s+`)
+	markdownLinkRx    = regexp.MustCompile(`^\[([^\]]+)\]\(([^()\s]+)\)`)
+	markdownAutoRx    = regexp.MustCompile(`^<(https?://[^>\s]+)>`)
+)
+
+// writeMarkdownInline renders inline Markdown markup within a single block.
+func writeMarkdownInline(sb *strings.Builder, text string) {
+	for text != "" {
+		switch {
+		case text[0] == '`':
+			if end := strings.IndexByte(text[1:], '`'); end >= 0 {
+				sb.WriteString("<code>" + html.EscapeString(text[1:1+end]) + "</code>")
+				text = text[end+2:]
+				continue
+			}
+		case strings.HasPrefix(text, "**"):
+			if end := strings.Index(text[2:], "**"); end > 0 {
+				sb.WriteString("<strong>")
+				writeMarkdownInline(sb, text[2:2+end])
+				sb.WriteString("</strong>")
+				text = text[end+4:]
+				continue
+			}
+		case text[0] == '*':
+			if end := strings.IndexByte(text[1:], '*'); end > 0 {
+				sb.WriteString("<em>")
+				writeMarkdownInline(sb, text[1:1+end])
+				sb.WriteString("</em>")
+				text = text[end+2:]
+				continue
+			}
+		case text[0] == '[':
+			if match := markdownLinkRx.FindStringSubmatch(text); match != nil {
+				if isSafeLinkTarget(match[2]) {
+					sb.WriteString(`<a href="` + html.EscapeString(match[2]) + `" rel="noreferrer">`)
+					writeMarkdownInline(sb, match[1])
+					sb.WriteString("</a>")
+				} else {
+					writeMarkdownInline(sb, match[1])
+				}
+				text = text[len(match[0]):]
+				continue
+			}
+		case text[0] == '<':
+			if match := markdownAutoRx.FindStringSubmatch(text); match != nil {
+				sb.WriteString(`<a href="` + html.EscapeString(match[1]) + `" rel="noreferrer">` + html.EscapeString(match[1]) + "</a>")
+				text = text[len(match[0]):]
+				continue
+			}
+		}
+
+		// no markup here: copy plain text until the next character that might start markup
+		next := strings.IndexAny(text[1:], "`*[<")
+		if next < 0 {
+			next = len(text) - 1
+		}
+		sb.WriteString(html.EscapeString(text[:next+1]))
+		text = text[next+1:]
+	}
+}
+
+// isSafeLinkTarget only allows absolute http and https URLs as link targets.
+// This rejects schemes like "javascript:" as well as relative URLs, which would point into doop-api itself.
+func isSafeLinkTarget(target string) bool {
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"html/template"
+	"testing"
+
+	"go.xyrillian.de/gg/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	testCases := []struct {
+		Input    string
+		Expected template.HTML
+	}{
+		{"", ""},
+		{
+			"Images must be pinned\nto a **specific** tag.\n\nSee *also*: `image:tag`.",
+			"<p>Images must be pinned to a <strong>specific</strong> tag.</p>\n<p>See <em>also</em>: <code>image:tag</code>.</p>\n",
+		},
+		{
+			"# How to fix\n- first step\n- second\n  step\n\n1. one\n2. two",
+			"<h4>How to fix</h4>\n<ul>\n<li>first step</li>\n<li>second step</li>\n</ul>\n<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n",
+		},
+		{
+			"Example:\n```\nspec:\n  image: <name>:1.0\n```",
+			"<p>Example:</p>\n<pre><code>spec:\n  image: &lt;name&gt;:1.0</code></pre>\n",
+		},
+		{
+			"[docs](https://example.com/docs?a=1&b=2) and <https://example.com>",
+			`<p><a href="https://example.com/docs?a=1&amp;b=2" rel="noreferrer">docs</a> and <a href="https://example.com" rel="noreferrer">https://example.com</a></p>` + "\n",
+		},
+		// raw HTML and unsafe links are neutralized
+		{
+			`<script>alert("hi")</script> [click](javascript:alert(1))`,
+			"<p>&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt; [click](javascript:alert(1))</p>\n",
+		},
+		{
+			`[click](javascript:void) [mail](mailto:foo@example.com) [relative](/v2/violations) [scheme](JavaScript:void)`,
+			"<p>click mail relative scheme</p>\n",
+		},
+		// unterminated markup is shown as text
+		{"2 * 3 and `x", "<p>2 * 3 and `x</p>\n"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, renderMarkdown(tc.Input), tc.Expected)
+	}
+}
@@ -4,7 +4,6 @@
 package main
 
 import (
-	"context"
 	"net/url"
 	"os"
 	"regexp"
@@ -13,6 +12,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sapcc/go-bits/logg"
+	"github.com/sapcc/go-bits/must"
 
 	"github.com/sapcc/gatekeeper-addons/internal/doop"
 )
@@ -21,9 +21,13 @@ import (
 type MetricCollector struct {
 	downloader             *Downloader
 	objectIdentityKeys     []string
+	filterSet              FilterSet
 	rawViolationsGauge     *prometheus.GaugeVec
 	groupedViolationsGauge *prometheus.GaugeVec
 	auditAgeOldestGauge    *prometheus.GaugeVec
+	brokenMutatorsGauge    *prometheus.GaugeVec
+	staleReportsGauge      *prometheus.GaugeVec
+	reportErrorsGauge      *prometheus.GaugeVec
 }
 
 // NewMetricCollector initializes a MetricCollector.
@@ -38,9 +42,13 @@ func NewMetricCollector(downloader *Downloader) *MetricCollector {
 		objectIdentityLabels[idx] = rx.ReplaceAllString(key, "_")
 	}
 
+	filterQuery := must.Return(url.ParseQuery(os.Getenv("DOOP_API_METRICS_FILTER")))
+	filterSet := must.Return(BuildFilterSet(filterQuery))
+
 	return &MetricCollector{
 		downloader:         downloader,
 		objectIdentityKeys: objectIdentityKeys,
+		filterSet:          filterSet,
 		rawViolationsGauge: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "doop_raw_violations",
@@ -62,6 +70,27 @@ func NewMetricCollector(downloader *Downloader) *MetricCollector {
 			},
 			[]string{"cluster"},
 		),
+		brokenMutatorsGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "doop_broken_mutators",
+				Help: "Number of Gatekeeper mutators that are not enforced or have errors, for each source cluster.",
+			},
+			[]string{"cluster"},
+		),
+		staleReportsGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "doop_stale_reports",
+				Help: "Whether the report for each source cluster is stale (1) or not (0).",
+			},
+			[]string{"cluster"},
+		),
+		reportErrorsGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "doop_report_errors",
+				Help: "Whether the latest report for each source cluster could not be downloaded or decoded (1) or not (0).",
+			},
+			[]string{"cluster"},
+		),
 	}
 }
 
@@ -70,6 +99,9 @@ func (mc *MetricCollector) Describe(ch chan<- *prometheus.Desc) {
 	mc.rawViolationsGauge.Describe(ch)
 	mc.groupedViolationsGauge.Describe(ch)
 	mc.auditAgeOldestGauge.Describe(ch)
+	mc.brokenMutatorsGauge.Describe(ch)
+	mc.staleReportsGauge.Describe(ch)
+	mc.reportErrorsGauge.Describe(ch)
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -81,22 +113,64 @@ func (mc *MetricCollector) Collect(ch chan<- prometheus.Metric) {
 	groupedViolationsDesc := <-descCh
 	mc.auditAgeOldestGauge.Describe(descCh)
 	auditAgeOldestDesc := <-descCh
+	mc.brokenMutatorsGauge.Describe(descCh)
+	brokenMutatorsDesc := <-descCh
+	mc.staleReportsGauge.Describe(descCh)
+	staleReportsDesc := <-descCh
+	mc.reportErrorsGauge.Describe(descCh)
+	reportErrorsDesc := <-descCh
 
-	// using the individual reports, we can immediately calculate the audit age
-	reports, err := mc.downloader.GetReports(context.Background()) // Prometheus does not give us a better ctx here :(
-	if err != nil {
-		logg.Error("could not download reports for metric computation: %s", err.Error())
+	// using the individual reports, we can immediately calculate the errors, the staleness, the audit age and the number of broken mutators
+	reports, reportErrs := mc.downloader.GetReports()
+	for clusterName := range reportErrs {
+		// reports without any good version cannot be matched against the filter, so they are always reported
+		if _, exists := reports[clusterName]; !exists {
+			ch <- prometheus.MustNewConstMetric(
+				reportErrorsDesc,
+				prometheus.GaugeValue, 1,
+				clusterName,
+			)
+		}
 	}
 	for clusterName, report := range reports {
+		if !mc.filterSet.MatchClusterIdentity(report.ClusterIdentity) {
+			continue
+		}
+		errorValue := 0.0
+		if reportErrs[clusterName] != nil {
+			errorValue = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(
+			reportErrorsDesc,
+			prometheus.GaugeValue, errorValue,
+			clusterName,
+		)
+		staleValue := 0.0
+		if report.IsStale {
+			staleValue = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(
+			staleReportsDesc,
+			prometheus.GaugeValue, staleValue,
+			clusterName,
+		)
 		ch <- prometheus.MustNewConstMetric(
 			auditAgeOldestDesc,
 			prometheus.GaugeValue, oldestAuditAgeForClusterReport(clusterName, report),
 			clusterName,
 		)
+		if !mc.filterSet.MatchStaleness(report.IsStale) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			brokenMutatorsDesc,
+			prometheus.GaugeValue, float64(countBrokenMutators(report)),
+			clusterName,
+		)
 	}
 
 	// counting violation groups requires an aggregated report
-	fullReport := AggregateReports(reports, BuildFilterSet(url.Values{}))
+	fullReport := AggregateReports(reports, mc.filterSet)
 	for _, rt := range fullReport.Templates {
 		// there may be multiple constraints with the same name if metadata differs;
 		// to avoid reporting metrics with the same labelsets multiple times,
@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// AggregateMutators collects the mutator status from a set of individual reports into an AggregatedMutatorReport.
+// If onlyBroken is true, only mutators that are not working correctly are included.
+func AggregateMutators(reports map[string]doop.Report, f FilterSet, onlyBroken bool) doop.AggregatedMutatorReport {
+	target := doop.AggregatedMutatorReport{
+		ClusterIdentities: make(map[string]map[string]string),
+		Mutators:          []doop.ReportForMutator{},
+	}
+
+	for clusterName, clusterReport := range reports {
+		if !f.MatchClusterIdentity(clusterReport.ClusterIdentity) || !f.MatchStaleness(clusterReport.IsStale) {
+			continue
+		}
+		target.ClusterIdentities[clusterName] = clusterReport.ClusterIdentity
+		for _, mr := range clusterReport.Mutators {
+			if onlyBroken && !mr.IsBroken() {
+				continue
+			}
+			target.Mutators = append(target.Mutators, mr)
+		}
+	}
+
+	return target
+}
+
+// countBrokenMutators returns the number of broken mutators in the given report.
+func countBrokenMutators(report doop.Report) int {
+	count := 0
+	for _, mr := range report.Mutators {
+		if mr.IsBroken() {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestAggregateMutators(t *testing.T) {
+	healthyPods := []doop.MutatorPodStatus{{ID: "gatekeeper-1", Enforced: true}, {ID: "gatekeeper-2", Enforced: true}}
+	conflictingPods := []doop.MutatorPodStatus{{
+		ID:       "gatekeeper-1",
+		Enforced: false,
+		Errors:   []doop.MutatorError{{Type: "ErrConflictingSchema", Message: "conflict"}},
+	}}
+	reports := map[string]doop.Report{
+		"cluster1": doop.Report{
+			ClusterIdentity: map[string]string{"region": "one"},
+			Mutators: []doop.ReportForMutator{
+				{Kind: "Assign", Name: "pull-policy", Pods: healthyPods},
+				{Kind: "AssignMetadata", Name: "owner-label", Pods: conflictingPods},
+			},
+		}.SetClusterName("cluster1"),
+		"cluster2": doop.Report{
+			ClusterIdentity: map[string]string{"region": "two"},
+			Mutators: []doop.ReportForMutator{
+				{Kind: "Assign", Name: "pull-policy", Pods: healthyPods},
+				{Kind: "ModifySet", Name: "sidecar-args", Pods: []doop.MutatorPodStatus{}},
+			},
+		}.SetClusterName("cluster2"),
+	}
+	clusterIdentities := map[string]map[string]string{
+		"cluster1": {"region": "one"},
+		"cluster2": {"region": "two"},
+	}
+
+	// without filters, all mutators are reported
+	actual := AggregateMutators(reports, must.ReturnT(BuildFilterSet(url.Values{}))(t), false)
+	actual.Sort()
+	assert.Equal(t, actual, doop.AggregatedMutatorReport{
+		ClusterIdentities: clusterIdentities,
+		Mutators: []doop.ReportForMutator{
+			{Kind: "Assign", Name: "pull-policy", Pods: healthyPods, ClusterName: "cluster1"},
+			{Kind: "Assign", Name: "pull-policy", Pods: healthyPods, ClusterName: "cluster2"},
+			{Kind: "AssignMetadata", Name: "owner-label", Pods: conflictingPods, ClusterName: "cluster1"},
+			{Kind: "ModifySet", Name: "sidecar-args", Pods: []doop.MutatorPodStatus{}, ClusterName: "cluster2"},
+		},
+	})
+
+	// mutators that are not enforced by any pod count as broken
+	actual = AggregateMutators(reports, must.ReturnT(BuildFilterSet(url.Values{}))(t), true)
+	actual.Sort()
+	assert.Equal(t, actual, doop.AggregatedMutatorReport{
+		ClusterIdentities: clusterIdentities,
+		Mutators: []doop.ReportForMutator{
+			{Kind: "AssignMetadata", Name: "owner-label", Pods: conflictingPods, ClusterName: "cluster1"},
+			{Kind: "ModifySet", Name: "sidecar-args", Pods: []doop.MutatorPodStatus{}, ClusterName: "cluster2"},
+		},
+	})
+	assert.Equal(t, countBrokenMutators(reports["cluster1"]), 1)
+	assert.Equal(t, countBrokenMutators(reports["cluster2"]), 1)
+
+	// cluster identity filters apply
+	actual = AggregateMutators(reports, must.ReturnT(BuildFilterSet(query("cluster_identity.region=two")))(t), true)
+	actual.Sort()
+	assert.Equal(t, actual, doop.AggregatedMutatorReport{
+		ClusterIdentities: map[string]map[string]string{"cluster2": {"region": "two"}},
+		Mutators: []doop.ReportForMutator{
+			{Kind: "ModifySet", Name: "sidecar-args", Pods: []doop.MutatorPodStatus{}, ClusterName: "cluster2"},
+		},
+	})
+}
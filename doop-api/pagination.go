@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sapcc/go-bits/must"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// Pagination describes which part of an aggregated report shall be shown by GET /v2/violations.
+// The zero value shows everything.
+type Pagination struct {
+	// Maximum number of violation groups to show (0 = unlimited).
+	Limit int
+	// If not nil, only violation groups after this position are shown. Decoded from the `cursor` query argument.
+	After *cursorPosition
+	// Maximum number of instances to show per violation group (0 = unlimited).
+	InstancesLimit int
+}
+
+// ParsePagination collects pagination settings from the given URL query.
+func ParsePagination(query url.Values) (Pagination, error) {
+	var (
+		p   Pagination
+		err error
+	)
+	p.Limit, err = parsePositiveInt(query, "limit")
+	if err != nil {
+		return Pagination{}, err
+	}
+	p.InstancesLimit, err = parsePositiveInt(query, "instances_limit")
+	if err != nil {
+		return Pagination{}, err
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		p.After, err = decodeCursor(cursor)
+		if err != nil {
+			return Pagination{}, fmt.Errorf("invalid value for cursor: %q", cursor)
+		}
+	}
+	return p, nil
+}
+
+func parsePositiveInt(query url.Values, key string) (int, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil || result <= 0 {
+		return 0, fmt.Errorf("invalid value for %s: %q (expected positive integer)", key, value)
+	}
+	return result, nil
+}
+
+// cursorPosition identifies a violation group in the canonical sort order of an AggregatedReport.
+// Cursors contain the position of the last violation group on their page, so that the next page continues after it,
+// even if violation groups were added or removed in the meantime.
+type cursorPosition struct {
+	TemplateKind string `json:"template_kind"`
+	// Constraints with the same name can appear multiple times if their metadata differs, see ReportForTemplate.Sort().
+	ConstraintName     string                     `json:"constraint_name"`
+	ConstraintMetadata doop.MetadataForConstraint `json:"constraint_metadata"`
+	Pattern            doop.Violation             `json:"pattern"`
+}
+
+// compareTo follows the same conventions as Violation.CompareTo(), and the same order as AggregatedReport.Sort().
+func (c cursorPosition) compareTo(other cursorPosition) int {
+	return cmp.Or(
+		strings.Compare(c.TemplateKind, other.TemplateKind),
+		strings.Compare(c.ConstraintName, other.ConstraintName),
+		c.ConstraintMetadata.CompareTo(other.ConstraintMetadata),
+		c.Pattern.CompareTo(other.Pattern),
+	)
+}
+
+// Cursors are opaque to clients, so that we can change their format later without breaking anyone.
+const cursorPrefix = "after:"
+
+func encodeCursor(pos cursorPosition) string {
+	buf := must.Return(json.Marshal(pos))
+	return base64.RawURLEncoding.EncodeToString(append([]byte(cursorPrefix), buf...))
+}
+
+func decodeCursor(cursor string) (*cursorPosition, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	posJSON, ok := bytes.CutPrefix(buf, []byte(cursorPrefix))
+	if !ok {
+		return nil, errors.New("missing prefix")
+	}
+	var pos cursorPosition
+	err = json.Unmarshal(posJSON, &pos)
+	if err != nil {
+		return nil, err
+	}
+	return &pos, nil
+}
+
+// Apply removes everything from the given report that is not on the requested page.
+// The report must already be sorted, since pages are cut in the canonical sort order of violation groups.
+// If there are more violation groups after this page, the cursor for the next page is returned.
+func (p Pagination) Apply(report *doop.AggregatedReport) (nextCursor string) {
+	if p.InstancesLimit > 0 {
+		for _, rt := range report.Templates {
+			for _, rc := range rt.Constraints {
+				for idx := range rc.ViolationGroups {
+					vg := &rc.ViolationGroups[idx]
+					vg.TotalInstances = len(vg.Instances)
+					if len(vg.Instances) > p.InstancesLimit {
+						vg.Instances = vg.Instances[:p.InstancesLimit]
+					}
+				}
+			}
+		}
+	}
+
+	if p.Limit == 0 && p.After == nil {
+		return ""
+	}
+
+	// admission events are not paginated, so they are only shown on the first page
+	if p.After != nil {
+		report.Admissions = nil
+	}
+
+	var (
+		templates []doop.ReportForTemplate
+		count     int
+		lastPos   cursorPosition
+		hasMore   bool
+	)
+	for _, rt := range report.Templates {
+		var constraints []doop.ReportForConstraint
+		for _, rc := range rt.Constraints {
+			var groups []doop.ViolationGroup
+			for _, vg := range rc.ViolationGroups {
+				pos := cursorPosition{rt.Kind, rc.Name, rc.Metadata, vg.Pattern}
+				if p.After != nil && pos.compareTo(*p.After) <= 0 {
+					continue
+				}
+				if p.Limit > 0 && count >= p.Limit {
+					hasMore = true
+					break
+				}
+				groups = append(groups, vg)
+				count++
+				lastPos = pos
+			}
+			if len(groups) > 0 {
+				rc.ViolationGroups = groups
+				constraints = append(constraints, rc)
+			}
+		}
+		if len(constraints) > 0 {
+			rt.Constraints = constraints
+			templates = append(templates, rt)
+		}
+	}
+	report.Templates = templates
+
+	if hasMore {
+		return encodeCursor(lastPos)
+	}
+	return ""
+}
+
+// urlWithCursor builds a link to the given path with the given query, but with a different cursor (or none if empty).
+func urlWithCursor(path string, query url.Values, cursor string) string {
+	q := make(url.Values, len(query))
+	maps.Copy(q, query)
+	delete(q, "cursor")
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	return (&url.URL{Path: path, RawQuery: q.Encode()}).String()
+}
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/base64"
+	"net/url"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestPaginationOverViolationGroups(t *testing.T) {
+	// 2 templates * 2 constraints * 3 groups = 12 violation groups in total
+	reports := makeSyntheticReports(2, 2, 2, 3)
+	aggregate := func() doop.AggregatedReport {
+		result := AggregateReports(reports, must.ReturnT(BuildFilterSet(url.Values{}))(t))
+		result.Sort()
+		return result
+	}
+	full := aggregate()
+
+	// the zero value does not change anything
+	result := aggregate()
+	assert.Equal(t, Pagination{}.Apply(&result), "")
+	assert.Equal(t, result, full)
+
+	// walking through all pages yields all violation groups in the canonical order
+	var (
+		pageSizes  []int
+		seenGroups []doop.ViolationGroup
+		p          = Pagination{Limit: 5}
+	)
+	for {
+		result := aggregate()
+		nextCursor := p.Apply(&result)
+		groups := listViolationGroups(result)
+		pageSizes = append(pageSizes, len(groups))
+		seenGroups = append(seenGroups, groups...)
+		if nextCursor == "" {
+			break
+		}
+		p = must.ReturnT(ParsePagination(url.Values{"limit": {"5"}, "cursor": {nextCursor}}))(t)
+	}
+	assert.Equal(t, pageSizes, []int{5, 5, 2})
+	assert.Equal(t, seenGroups, listViolationGroups(full))
+
+	// when the last page is exactly full, there is no next page
+	result = aggregate()
+	nextCursor := Pagination{Limit: 6}.Apply(&result)
+	result = aggregate()
+	p = must.ReturnT(ParsePagination(url.Values{"limit": {"6"}, "cursor": {nextCursor}}))(t)
+	assert.Equal(t, p.Apply(&result), "")
+	assert.Equal(t, len(listViolationGroups(result)), 6)
+
+	// pages beyond the end are empty
+	result = aggregate()
+	lastTemplate := full.Templates[len(full.Templates)-1]
+	lastConstraint := lastTemplate.Constraints[len(lastTemplate.Constraints)-1]
+	lastGroup := lastConstraint.ViolationGroups[len(lastConstraint.ViolationGroups)-1]
+	p = Pagination{Limit: 5, After: &cursorPosition{lastTemplate.Kind, lastConstraint.Name, lastConstraint.Metadata, lastGroup.Pattern}}
+	assert.Equal(t, p.Apply(&result), "")
+	assert.Equal(t, len(result.Templates), 0)
+}
+
+func TestPaginationWhileReportsChange(t *testing.T) {
+	reports := makeSyntheticReports(2, 1, 1, 6)
+	aggregate := func() doop.AggregatedReport {
+		result := AggregateReports(reports, must.ReturnT(BuildFilterSet(url.Values{}))(t))
+		result.Sort()
+		return result
+	}
+	full := listViolationGroups(aggregate())
+
+	result := aggregate()
+	nextCursor := Pagination{Limit: 2}.Apply(&result)
+	assert.Equal(t, listViolationGroups(result), full[0:2])
+
+	// if a violation group from the first page disappears before the second page is requested,
+	// the second page still continues right after the last group from the first page
+	result = aggregate()
+	rc := &result.Templates[0].Constraints[0]
+	rc.ViolationGroups = rc.ViolationGroups[1:]
+	p := must.ReturnT(ParsePagination(url.Values{"limit": {"2"}, "cursor": {nextCursor}}))(t)
+	p.Apply(&result)
+	assert.Equal(t, listViolationGroups(result), full[2:4])
+}
+
+func TestPaginationOverInstances(t *testing.T) {
+	reports := makeSyntheticReports(3, 1, 1, 2)
+	result := AggregateReports(reports, must.ReturnT(BuildFilterSet(url.Values{}))(t))
+	result.Sort()
+
+	Pagination{InstancesLimit: 4}.Apply(&result)
+	for _, vg := range listViolationGroups(result) {
+		assert.Equal(t, len(vg.Instances), 4)
+		assert.Equal(t, vg.TotalInstances, 3*2)
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	p := must.ReturnT(ParsePagination(url.Values{}))(t)
+	assert.Equal(t, p, Pagination{})
+
+	pos := cursorPosition{
+		TemplateKind:       "GkFoo",
+		ConstraintName:     "foo",
+		ConstraintMetadata: doop.MetadataForConstraint{Severity: "error"},
+		Pattern:            doop.Violation{Kind: "Pod", ObjectIdentity: map[string]string{"service": "dns"}},
+	}
+	p = must.ReturnT(ParsePagination(url.Values{"limit": {"10"}, "instances_limit": {"3"}, "cursor": {encodeCursor(pos)}}))(t)
+	assert.Equal(t, p, Pagination{Limit: 10, After: &pos, InstancesLimit: 3})
+
+	badQueries := []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"-5"}},
+		{"limit": {"ten"}},
+		{"instances_limit": {"0"}},
+		{"cursor": {"not-a-cursor"}},
+		{"cursor": {base64.RawURLEncoding.EncodeToString([]byte("offset:20"))}},
+		{"cursor": {base64.RawURLEncoding.EncodeToString([]byte("after:[1,2,3]"))}},
+	}
+	for _, query := range badQueries {
+		_, err := ParsePagination(query)
+		if err == nil {
+			t.Errorf("expected error for %q, but got none", query.Encode())
+		}
+	}
+}
+
+func listViolationGroups(report doop.AggregatedReport) (result []doop.ViolationGroup) {
+	for _, rt := range report.Templates {
+		for _, rc := range rt.Constraints {
+			result = append(result, rc.ViolationGroups...)
+		}
+	}
+	return result
+}
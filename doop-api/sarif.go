@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// The types in this file cover the subset of SARIF 2.1.0 that we need.
+// Reference: <https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html>
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifToolComponent `json:"driver"`
+}
+
+type sarifToolComponent struct {
+	Name           string                     `json:"name"`
+	InformationURI string                     `json:"informationUri"`
+	Rules          []sarifReportingDescriptor `json:"rules"`
+}
+
+type sarifReportingDescriptor struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	Help                 *sarifMessage      `json:"help,omitempty"`
+	HelpURI              string             `json:"helpUri,omitempty"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           map[string]string  `json:"properties,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	RuleIndex  int             `json:"ruleIndex"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties map[string]any  `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevelForSeverity maps the values of the `severity` label of constraints to SARIF levels.
+func sarifLevelForSeverity(severity string) string {
+	switch severity {
+	case "error":
+		return "error"
+	case "info":
+		return "note"
+	case "debug":
+		return "none"
+	default:
+		// this is also the default level in SARIF
+		return "warning"
+	}
+}
+
+// encodeViolationsAsSARIF renders the given report as a SARIF log.
+// Each constraint becomes a rule, and each violation instance becomes a result for that rule.
+func encodeViolationsAsSARIF(report doop.AggregatedReport) ([]byte, error) {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifToolComponent{
+			Name:           "doop-api",
+			InformationURI: "https://github.com/sapcc/gatekeeper-addons",
+			Rules:          []sarifReportingDescriptor{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	// rule IDs need to be unique, but there may be multiple constraints with the same name if metadata differs
+	isRuleIDUsed := make(map[string]bool)
+
+	for _, rt := range report.Templates {
+		for _, rc := range rt.Constraints {
+			ruleID := rt.Kind + "/" + rc.Name
+			for suffix := 2; isRuleIDUsed[ruleID]; suffix++ {
+				ruleID = rt.Kind + "/" + rc.Name + "#" + strconv.Itoa(suffix)
+			}
+			isRuleIDUsed[ruleID] = true
+
+			rule := sarifReportingDescriptor{
+				ID:                   ruleID,
+				Name:                 rc.Name,
+				ShortDescription:     sarifMessage{Text: fmt.Sprintf("Gatekeeper constraint %s of kind %s", rc.Name, rt.Kind)},
+				HelpURI:              cmp.Or(rc.Metadata.ConstraintSource, rc.Metadata.TemplateSource),
+				DefaultConfiguration: sarifConfiguration{Level: sarifLevelForSeverity(rc.Metadata.Severity)},
+				Properties: map[string]string{
+					"template_kind": rt.Kind,
+				},
+			}
+			if rc.Metadata.Docstring != "" {
+				rule.Help = &sarifMessage{Text: rc.Metadata.Docstring}
+			}
+			if rc.Metadata.Severity != "" {
+				rule.Properties["severity"] = rc.Metadata.Severity
+			}
+			if rc.Metadata.ConstraintSource != "" {
+				rule.Properties["constraint_source"] = rc.Metadata.ConstraintSource
+			}
+			if rc.Metadata.TemplateSource != "" {
+				rule.Properties["template_source"] = rc.Metadata.TemplateSource
+			}
+			ruleIndex := len(run.Tool.Driver.Rules)
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+
+			for _, vg := range rc.ViolationGroups {
+				for _, instance := range vg.Instances {
+					run.Results = append(run.Results, sarifResultForViolation(instance.ExpandedFrom(vg.Pattern), rule, ruleIndex))
+				}
+			}
+		}
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(log)
+	if err != nil {
+		return nil, fmt.Errorf("while encoding violations sarif: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func sarifResultForViolation(v doop.Violation, rule sarifReportingDescriptor, ruleIndex int) sarifResult {
+	// the fully qualified name identifies the object across all clusters, e.g. "cluster1/namespace/Pod/name"
+	// (for cluster-scoped objects, there is no namespace part, e.g. "cluster1/Node/name")
+	fqnParts := []string{v.ClusterName}
+	if v.Namespace != "" {
+		fqnParts = append(fqnParts, v.Namespace)
+	}
+	fqnParts = append(fqnParts, v.Kind, v.Name)
+
+	result := sarifResult{
+		RuleID:    rule.ID,
+		RuleIndex: ruleIndex,
+		Level:     rule.DefaultConfiguration.Level,
+		Message:   sarifMessage{Text: v.Message},
+		Locations: []sarifLocation{{
+			LogicalLocations: []sarifLogicalLocation{{
+				Name:               v.Name,
+				FullyQualifiedName: strings.Join(fqnParts, "/"),
+				Kind:               "resource",
+			}},
+		}},
+		Properties: map[string]any{
+			"cluster":   v.ClusterName,
+			"kind":      v.Kind,
+			"namespace": v.Namespace,
+			"name":      v.Name,
+		},
+	}
+	if len(v.ObjectIdentity) > 0 {
+		result.Properties["object_identity"] = v.ObjectIdentity
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestEncodeViolationsAsSARIF(t *testing.T) {
+	report := doop.AggregatedReport{
+		Templates: []doop.ReportForTemplate{{
+			Kind: "GkImageTag",
+			Constraints: []doop.ReportForConstraint{
+				{
+					Name: "imagetag",
+					Metadata: doop.MetadataForConstraint{
+						Severity:         "info",
+						TemplateSource:   "https://example.com/templates/image-tag.yaml",
+						ConstraintSource: "https://example.com/constraints/imagetag.yaml",
+						Docstring:        "Images should be pinned to a specific tag.",
+					},
+					ViolationGroups: []doop.ViolationGroup{{
+						Pattern: doop.Violation{
+							Kind:           "Pod",
+							Namespace:      "kube-system",
+							Message:        "image uses the latest tag",
+							ObjectIdentity: map[string]string{"service": "dns"},
+						},
+						Instances: []doop.Violation{
+							{Name: "coredns-1", ClusterName: "cluster1"},
+							{Name: "coredns-2", ClusterName: "cluster2", Message: "image has no tag"},
+						},
+					}},
+				},
+				{
+					// same name as before, but different metadata
+					Name:     "imagetag",
+					Metadata: doop.MetadataForConstraint{TemplateSource: "https://example.com/templates/image-tag.yaml"},
+					ViolationGroups: []doop.ViolationGroup{{
+						Pattern:   doop.Violation{Kind: "Node", Message: "node image uses the latest tag"},
+						Instances: []doop.Violation{{Name: "node-1", ClusterName: "cluster1"}},
+					}},
+				},
+			},
+		}},
+	}
+
+	var actual sarifLog
+	must.SucceedT(t, json.Unmarshal(must.ReturnT(encodeViolationsAsSARIF(report))(t), &actual))
+	assert.Equal(t, actual.Version, "2.1.0")
+	assert.Equal(t, len(actual.Runs), 1)
+	run := actual.Runs[0]
+
+	// each constraint becomes a rule
+	assert.Equal(t, run.Tool.Driver.Rules, []sarifReportingDescriptor{
+		{
+			ID:                   "GkImageTag/imagetag",
+			Name:                 "imagetag",
+			ShortDescription:     sarifMessage{Text: "Gatekeeper constraint imagetag of kind GkImageTag"},
+			Help:                 &sarifMessage{Text: "Images should be pinned to a specific tag."},
+			HelpURI:              "https://example.com/constraints/imagetag.yaml",
+			DefaultConfiguration: sarifConfiguration{Level: "note"},
+			Properties: map[string]string{
+				"template_kind":     "GkImageTag",
+				"severity":          "info",
+				"constraint_source": "https://example.com/constraints/imagetag.yaml",
+				"template_source":   "https://example.com/templates/image-tag.yaml",
+			},
+		},
+		{
+			ID:                   "GkImageTag/imagetag#2",
+			Name:                 "imagetag",
+			ShortDescription:     sarifMessage{Text: "Gatekeeper constraint imagetag of kind GkImageTag"},
+			HelpURI:              "https://example.com/templates/image-tag.yaml",
+			DefaultConfiguration: sarifConfiguration{Level: "warning"},
+			Properties: map[string]string{
+				"template_kind":   "GkImageTag",
+				"template_source": "https://example.com/templates/image-tag.yaml",
+			},
+		},
+	})
+
+	// each instance becomes a result
+	assert.Equal(t, len(run.Results), 3)
+	assert.Equal(t, run.Results[1], sarifResult{
+		RuleID:    "GkImageTag/imagetag",
+		RuleIndex: 0,
+		Level:     "note",
+		Message:   sarifMessage{Text: "image has no tag"},
+		Locations: []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{
+			Name:               "coredns-2",
+			FullyQualifiedName: "cluster2/kube-system/Pod/coredns-2",
+			Kind:               "resource",
+		}}}},
+		Properties: map[string]any{
+			"cluster":         "cluster2",
+			"kind":            "Pod",
+			"namespace":       "kube-system",
+			"name":            "coredns-2",
+			"object_identity": map[string]any{"service": "dns"},
+		},
+	})
+	assert.Equal(t, run.Results[2].RuleIndex, 1)
+	assert.Equal(t, run.Results[2].Locations[0].LogicalLocations[0].FullyQualifiedName, "cluster1/Node/node-1")
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+// Summary is the data structure that is returned by GET /v2/summary.
+type Summary struct {
+	Totals  SummaryCounts  `json:"totals"`
+	Entries []SummaryEntry `json:"entries"`
+}
+
+// SummaryCounts appears in types Summary and SummaryEntry.
+type SummaryCounts struct {
+	Violations      int `json:"violations"`
+	ViolationGroups int `json:"violation_groups"`
+	// Keys are cluster names. Only violations can be counted per cluster, since violation groups may span multiple clusters.
+	ViolationsByCluster map[string]int `json:"violations_by_cluster"`
+}
+
+// SummaryEntry appears in type Summary. It counts the violations for one
+// constraint, and (if requested) for one set of object identity values.
+type SummaryEntry struct {
+	TemplateKind   string `json:"template_kind"`
+	ConstraintName string `json:"constraint_name"`
+	Severity       string `json:"severity,omitempty"`
+	// Only contains the keys that were requested in the `group_by` query argument.
+	ObjectIdentity map[string]string `json:"object_identity,omitempty"`
+	SummaryCounts
+}
+
+// ParseSummaryGroupKeys collects the object identity keys from the `group_by` query argument.
+func ParseSummaryGroupKeys(groupBy []string) ([]string, error) {
+	result := make([]string, 0, len(groupBy))
+	for _, value := range groupBy {
+		key, ok := strings.CutPrefix(value, "object_identity.")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid value for group_by: %q (expected \"object_identity.$KEY\")", value)
+		}
+		result = append(result, key)
+	}
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
+
+// SummarizeReport counts the violations and violation groups in the given aggregated report.
+// Violation groups are additionally sorted into entries by the values of the given object identity keys.
+func SummarizeReport(report doop.AggregatedReport, oidKeys []string) Summary {
+	result := Summary{
+		Totals:  SummaryCounts{ViolationsByCluster: make(map[string]int)},
+		Entries: []SummaryEntry{},
+	}
+
+	// There may be multiple constraints with the same name if metadata differs,
+	// so entries are identified by everything that ends up in the entry.
+	// Since we do not know how many oid keys we will have in advance,
+	// we merge them all together into one string with "\0" as a field separator.
+	entryIndexByKey := make(map[string]int)
+
+	for _, rt := range report.Templates {
+		for _, rc := range rt.Constraints {
+			for _, vg := range rc.ViolationGroups {
+				keyFields := make([]string, 3, 3+len(oidKeys))
+				keyFields[0] = rt.Kind
+				keyFields[1] = rc.Name
+				keyFields[2] = rc.Metadata.Severity
+				for _, key := range oidKeys {
+					keyFields = append(keyFields, vg.Pattern.ObjectIdentity[key])
+				}
+				entryKey := strings.Join(keyFields, "\000")
+
+				idx, exists := entryIndexByKey[entryKey]
+				if !exists {
+					entry := SummaryEntry{
+						TemplateKind:   rt.Kind,
+						ConstraintName: rc.Name,
+						Severity:       rc.Metadata.Severity,
+						SummaryCounts:  SummaryCounts{ViolationsByCluster: make(map[string]int)},
+					}
+					if len(oidKeys) > 0 {
+						entry.ObjectIdentity = make(map[string]string, len(oidKeys))
+						for _, key := range oidKeys {
+							entry.ObjectIdentity[key] = vg.Pattern.ObjectIdentity[key]
+						}
+					}
+					idx = len(result.Entries)
+					entryIndexByKey[entryKey] = idx
+					result.Entries = append(result.Entries, entry)
+				}
+
+				entry := &result.Entries[idx]
+				entry.ViolationGroups++
+				result.Totals.ViolationGroups++
+				for _, v := range vg.Instances {
+					clusterName := v.ExpandedFrom(vg.Pattern).ClusterName
+					entry.Violations++
+					entry.ViolationsByCluster[clusterName]++
+					result.Totals.Violations++
+					result.Totals.ViolationsByCluster[clusterName]++
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(result.Entries, func(lhs, rhs SummaryEntry) int {
+		return cmp.Or(
+			strings.Compare(lhs.TemplateKind, rhs.TemplateKind),
+			strings.Compare(lhs.ConstraintName, rhs.ConstraintName),
+			strings.Compare(lhs.Severity, rhs.Severity),
+			compareStringMapsByKeys(lhs.ObjectIdentity, rhs.ObjectIdentity, oidKeys),
+		)
+	})
+	return result
+}
+
+func compareStringMapsByKeys(lhs, rhs map[string]string, keys []string) int {
+	for _, key := range keys {
+		c := strings.Compare(lhs[key], rhs[key])
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
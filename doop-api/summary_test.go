@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestSummarizeReport(t *testing.T) {
+	inputSet := map[string]doop.Report{
+		"cluster1": mustParseJSON[doop.Report](t, "fixtures/input-cluster1.json").SetClusterName("cluster1"),
+		"cluster2": mustParseJSON[doop.Report](t, "fixtures/input-cluster2.json").SetClusterName("cluster2"),
+		"cluster3": mustParseJSON[doop.Report](t, "fixtures/input-cluster3.json").SetClusterName("cluster3"),
+		"cluster4": mustParseJSON[doop.Report](t, "fixtures/input-cluster4.json").SetClusterName("cluster4"),
+	}
+	aggregated := AggregateReports(inputSet, must.ReturnT(BuildFilterSet(url.Values{}))(t))
+
+	// without grouping, there is one entry per constraint
+	actual := SummarizeReport(aggregated, nil)
+	assert.Equal(t, actual, Summary{
+		Totals: SummaryCounts{
+			Violations:          4,
+			ViolationGroups:     3,
+			ViolationsByCluster: map[string]int{"cluster1": 1, "cluster2": 1, "cluster3": 1, "cluster4": 1},
+		},
+		Entries: []SummaryEntry{
+			{
+				TemplateKind:   "GkFirstTemplate",
+				ConstraintName: "firstconstraint",
+				Severity:       "info",
+				SummaryCounts: SummaryCounts{
+					Violations:          3,
+					ViolationGroups:     2,
+					ViolationsByCluster: map[string]int{"cluster1": 1, "cluster2": 1, "cluster3": 1},
+				},
+			},
+			{
+				TemplateKind:   "GkFirstTemplate",
+				ConstraintName: "secondconstraint",
+				Severity:       "info",
+				SummaryCounts: SummaryCounts{
+					Violations:          1,
+					ViolationGroups:     1,
+					ViolationsByCluster: map[string]int{"cluster4": 1},
+				},
+			},
+		},
+	})
+
+	// with grouping, the requested object identity values are shown
+	actual = SummarizeReport(aggregated, []string{"type"})
+	assert.Equal(t, actual.Entries[1].ObjectIdentity, map[string]string{"type": "production"})
+	assert.Equal(t, actual.Totals.ViolationGroups, 3)
+
+	// filters are applied during aggregation
+	aggregated = AggregateReports(inputSet, must.ReturnT(BuildFilterSet(query("constraint_name=secondconstraint")))(t))
+	actual = SummarizeReport(aggregated, nil)
+	assert.Equal(t, actual.Totals, SummaryCounts{
+		Violations:          1,
+		ViolationGroups:     1,
+		ViolationsByCluster: map[string]int{"cluster4": 1},
+	})
+}
+
+func TestParseSummaryGroupKeys(t *testing.T) {
+	actual := must.ReturnT(ParseSummaryGroupKeys([]string{"object_identity.team", "object_identity.service", "object_identity.team"}))(t)
+	assert.Equal(t, actual, []string{"service", "team"})
+
+	_, err := ParseSummaryGroupKeys([]string{"cluster_identity.region"})
+	if err == nil {
+		t.Error("expected error for group_by=cluster_identity.region, but got none")
+	}
+}
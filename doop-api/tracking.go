@@ -0,0 +1,473 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sapcc/go-bits/errext"
+	"github.com/sapcc/go-bits/logg"
+	"go.xyrillian.de/schwift/v2"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+const (
+	// The prefix for the names of the Swift objects where ViolationTracker stores its state.
+	// Each cluster has its own object, see trackingObjectName().
+	trackingObjectPrefix = doop.APIStateObjectPrefix + "violation-tracking/"
+	// Violations are forgotten when they have been resolved (or not seen at all) for this long.
+	trackingRetention = 30 * 24 * time.Hour
+)
+
+// ViolationTracker remembers when each individual violation was first and last seen, and when it was resolved.
+// This is used for the `first_seen` field in GET /v2/violations, and for GET /v2/changes.
+//
+// The state is stored in Swift, so that all replicas of doop-api agree on it. Since Swift does not support
+// conditional writes, replicas may overwrite each other's updates. To make this harmless, all timestamps are taken
+// from the reports (instead of from the clock of the replica that observes them), and each update merges the state
+// from Swift with the state from the previous update. That way, all replicas compute the same state from the same
+// reports, and the next update restores whatever might have been lost.
+//
+// The state is sharded by cluster, with one Swift object per cluster. A shard is only downloaded or uploaded when
+// its cluster's report has changed, when another replica has changed the shard in Swift, or when it needs to be pruned.
+type ViolationTracker struct {
+	container *schwift.Container
+	state     atomic.Pointer[trackingState]
+}
+
+// trackingState is the immutable result of a ViolationTracker.Update() call.
+type trackingState struct {
+	// Keys are cluster names. Shards that did not change are shared with the previous trackingState.
+	Clusters map[string]*clusterTrackingState
+	// Changes whenever the contents change. This is included in the ETag of GET /v2/violations.
+	version string
+}
+
+// clusterTrackingState is the part of type trackingState that refers to a single cluster.
+// It is stored in Swift in the object with the name trackingObjectName(clusterName).
+type clusterTrackingState struct {
+	// Keys are from trackingKey().
+	Violations map[string]TrackedViolation `json:"violations"`
+	// The LastModified timestamp of the latest report version that was observed.
+	observedAt time.Time
+	// The Etag of the Swift object holding this shard, or "" if there is no such object.
+	etag string
+	// The earliest time at which prune() would remove something from this shard, or zero if there is nothing to prune.
+	pruneAt time.Time
+}
+
+// TrackedViolation appears in type clusterTrackingState, and in the response of GET /v2/changes.
+type TrackedViolation struct {
+	ClusterName    string            `json:"cluster"`
+	TemplateKind   string            `json:"template_kind"`
+	ConstraintName string            `json:"constraint_name"`
+	Severity       string            `json:"severity,omitempty"`
+	Kind           string            `json:"kind,omitempty"`
+	Namespace      string            `json:"namespace,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Message        string            `json:"message,omitempty"`
+	ObjectIdentity map[string]string `json:"object_identity,omitempty"`
+	// These timestamps are the LastModified timestamps of the respective versions of the report.
+	FirstSeen  time.Time  `json:"first_seen"`
+	LastSeen   time.Time  `json:"last_seen"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// trackingObjectName returns the name of the Swift object containing the tracking state for the given cluster.
+func trackingObjectName(clusterName string) string {
+	return trackingObjectPrefix + clusterName + ".json"
+}
+
+// NewViolationTracker creates a ViolationTracker. Before GetState() returns anything, Update() must be called at least once.
+func NewViolationTracker(container *schwift.Container) *ViolationTracker {
+	return &ViolationTracker{container: container}
+}
+
+// Run updates the tracker from the downloader's current reports at the given interval, until `ctx` expires.
+func (t *ViolationTracker) Run(ctx context.Context, downloader *Downloader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := t.Update(ctx, downloader.GetObjectStates(), time.Now())
+		if err != nil {
+			logg.Error("could not update violation tracking: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update merges the given reports into the tracking state in Swift, and publishes the result.
+// If some shards cannot be updated, their previous state remains in use, and the errors are returned.
+func (t *ViolationTracker) Update(ctx context.Context, objStates map[string]objectState, now time.Time) error {
+	// listing the shards is much cheaper than downloading them, and tells us which shards were changed by other replicas
+	iter := t.container.Objects()
+	iter.Prefix = trackingObjectPrefix
+	objInfos, err := iter.CollectDetailed(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot list violation tracking objects in Swift: %w", err)
+	}
+	remoteEtags := make(map[string]string, len(objInfos))
+	for _, objInfo := range objInfos {
+		clusterName, ok := strings.CutSuffix(strings.TrimPrefix(objInfo.Object.Name(), trackingObjectPrefix), ".json")
+		if ok {
+			remoteEtags[clusterName] = objInfo.Etag
+		}
+	}
+
+	// shards are considered for all clusters that have a report, a shard in Swift, or a shard in memory
+	// (the latter two are relevant for decommissioned clusters, whose violations are retained until they get pruned)
+	var previous map[string]*clusterTrackingState
+	if state := t.state.Load(); state != nil {
+		previous = state.Clusters
+	}
+	clusterNames := make(map[string]bool, len(objStates))
+	for clusterName := range objStates {
+		clusterNames[clusterName] = true
+	}
+	for clusterName := range remoteEtags {
+		clusterNames[clusterName] = true
+	}
+	for clusterName := range previous {
+		clusterNames[clusterName] = true
+	}
+
+	next := trackingState{Clusters: make(map[string]*clusterTrackingState, len(clusterNames))}
+	var errs errext.ErrorSet
+	for clusterName := range clusterNames {
+		shard, err := t.updateShard(ctx, clusterName, previous[clusterName], remoteEtags[clusterName], objStates[clusterName], now)
+		if err != nil {
+			errs.Add(err)
+			shard = previous[clusterName]
+		}
+		if shard != nil {
+			next.Clusters[clusterName] = shard
+		}
+	}
+
+	// since the Etags reflect the contents of all shards, they can be combined into the version
+	hash := sha256.New()
+	for _, clusterName := range slices.Sorted(maps.Keys(next.Clusters)) {
+		hash.Write([]byte(strconv.Itoa(len(clusterName)) + ":" + clusterName + next.Clusters[clusterName].etag + "\n"))
+	}
+	next.version = hex.EncodeToString(hash.Sum(nil)[:16])
+	t.state.Store(&next)
+	if !errs.IsEmpty() {
+		return errs.JoinedError(", ")
+	}
+	return nil
+}
+
+// updateShard brings the tracking state for a single cluster up to date.
+// The local shard is the result of the previous update, and may be nil if there is none.
+// The remote Etag is empty if there is no shard for this cluster in Swift.
+// The object state is empty if there is no report for this cluster.
+func (t *ViolationTracker) updateShard(ctx context.Context, clusterName string, local *clusterTrackingState, remoteEtag string, objState objectState, now time.Time) (*clusterTrackingState, error) {
+	// if the latest version of the report could not be loaded, the payload does not belong to the LastModified timestamp
+	hasNewReport := objState.HasPayload && objState.Error == nil && (local == nil || !objState.LastModified.Equal(local.observedAt))
+	// if the shard in Swift differs from ours, another replica has changed it (or it was lost and needs to be restored)
+	localEtag := ""
+	if local != nil {
+		localEtag = local.etag
+	}
+	if !hasNewReport && remoteEtag == localEtag && !local.needsPrune(now) {
+		return local, nil
+	}
+
+	objectName := trackingObjectName(clusterName)
+	obj := t.container.Object(objectName)
+	var remote *clusterTrackingState
+	if remoteEtag != "" && remoteEtag != localEtag {
+		remoteBytes, err := obj.Download(ctx, nil).AsByteSlice()
+		switch {
+		case schwift.Is(err, http.StatusNotFound):
+			// the shard was deleted after we listed it
+			remoteEtag = ""
+		case err != nil:
+			return nil, fmt.Errorf("cannot download %s from Swift: %w", objectName, err)
+		default:
+			remote = &clusterTrackingState{}
+			err = json.Unmarshal(remoteBytes, remote)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decode %s: %w", objectName, err)
+			}
+		}
+	}
+
+	next := mergeClusterTrackingStates(remote, local)
+	if hasNewReport {
+		next.observe(objState.Payload, objState.LastModified.UTC())
+		next.observedAt = objState.LastModified
+	}
+	next.prune(now)
+
+	if len(next.Violations) == 0 {
+		// empty shards are not stored in Swift, but are retained in memory to remember `observedAt`
+		if remoteEtag != "" {
+			err := obj.Delete(ctx, nil, nil)
+			if err != nil && !schwift.Is(err, http.StatusNotFound) {
+				return nil, fmt.Errorf("cannot delete %s from Swift: %w", objectName, err)
+			}
+		}
+		return next, nil
+	}
+
+	nextBytes, err := json.Marshal(next)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode %s: %w", objectName, err)
+	}
+	hash := md5.Sum(nextBytes) //nolint:gosec // not used for security; Swift uses MD5 for Etags
+	next.etag = hex.EncodeToString(hash[:])
+	if next.etag != remoteEtag {
+		err = obj.Upload(ctx, bytes.NewReader(nextBytes), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot upload %s to Swift: %w", objectName, err)
+		}
+	}
+	return next, nil
+}
+
+// mergeClusterTrackingStates combines the shard from Swift with the shard that this process had before.
+// Either of them may be nil, if there is no such shard. The result is always a new shard.
+func mergeClusterTrackingStates(remote, local *clusterTrackingState) *clusterTrackingState {
+	result := &clusterTrackingState{Violations: make(map[string]TrackedViolation)}
+	if remote != nil {
+		maps.Copy(result.Violations, remote.Violations)
+	}
+	if local == nil {
+		return result
+	}
+	result.observedAt = local.observedAt
+	for key, tv := range local.Violations {
+		if other, exists := result.Violations[key]; exists {
+			result.Violations[key] = mergeTrackedViolations(tv, other)
+		} else {
+			result.Violations[key] = tv
+		}
+	}
+	return result
+}
+
+// mergeTrackedViolations combines two records of the same violation.
+func mergeTrackedViolations(lhs, rhs TrackedViolation) TrackedViolation {
+	// if the violation was resolved and has appeared again since, the newer appearance replaces the older one
+	if lhs.ResolvedAt != nil && rhs.FirstSeen.After(*lhs.ResolvedAt) {
+		return rhs
+	}
+	if rhs.ResolvedAt != nil && lhs.FirstSeen.After(*rhs.ResolvedAt) {
+		return lhs
+	}
+
+	// otherwise both records describe the same appearance
+	result := lhs
+	if rhs.FirstSeen.Before(result.FirstSeen) {
+		result.FirstSeen = rhs.FirstSeen
+	}
+	if rhs.LastSeen.After(result.LastSeen) {
+		result.LastSeen = rhs.LastSeen
+	}
+	if rhs.ResolvedAt != nil && (result.ResolvedAt == nil || rhs.ResolvedAt.After(*result.ResolvedAt)) {
+		result.ResolvedAt = rhs.ResolvedAt
+	}
+	if result.ResolvedAt != nil && !result.LastSeen.Before(*result.ResolvedAt) {
+		result.ResolvedAt = nil
+	}
+	return result
+}
+
+// observe updates the shard with all violations in the given report, which was last modified at the given time.
+func (s *clusterTrackingState) observe(report doop.Report, observedAt time.Time) {
+	seenKeys := make(map[string]bool, len(s.Violations))
+	for _, rt := range report.Templates {
+		for _, rc := range rt.Constraints {
+			for _, vg := range rc.ViolationGroups {
+				for _, instance := range vg.Instances {
+					v := instance.ExpandedFrom(vg.Pattern)
+					key := trackingKey(rt.Kind, rc.Name, v)
+					seenKeys[key] = true
+
+					tv, exists := s.Violations[key]
+					if !exists || (tv.ResolvedAt != nil && observedAt.After(*tv.ResolvedAt)) {
+						// new violation (or a violation that reappeared after being resolved)
+						tv = TrackedViolation{
+							ClusterName:    v.ClusterName,
+							TemplateKind:   rt.Kind,
+							ConstraintName: rc.Name,
+							Severity:       rc.Metadata.Severity,
+							Kind:           v.Kind,
+							Namespace:      v.Namespace,
+							Name:           v.Name,
+							Message:        v.Message,
+							ObjectIdentity: v.ObjectIdentity,
+							FirstSeen:      observedAt,
+							LastSeen:       observedAt,
+						}
+					} else if observedAt.After(tv.LastSeen) {
+						tv.LastSeen = observedAt
+					}
+					s.Violations[key] = tv
+				}
+			}
+		}
+	}
+
+	// violations that were seen in an older version of this report, but not in this one, are resolved
+	for key, tv := range s.Violations {
+		if !seenKeys[key] && tv.ResolvedAt == nil && tv.LastSeen.Before(observedAt) {
+			tv.ResolvedAt = &observedAt
+			s.Violations[key] = tv
+		}
+	}
+}
+
+// prune removes violations that have been resolved (or not seen at all) for longer than the retention period.
+// The latter case usually happens when a cluster has been decommissioned.
+func (s *clusterTrackingState) prune(now time.Time) {
+	cutoff := now.Add(-trackingRetention)
+	s.pruneAt = time.Time{}
+	for key, tv := range s.Violations {
+		lastRelevantTime := tv.LastSeen
+		if tv.ResolvedAt != nil {
+			lastRelevantTime = *tv.ResolvedAt
+		}
+		if lastRelevantTime.Before(cutoff) {
+			delete(s.Violations, key)
+			continue
+		}
+		pruneAt := lastRelevantTime.Add(trackingRetention)
+		if s.pruneAt.IsZero() || pruneAt.Before(s.pruneAt) {
+			s.pruneAt = pruneAt
+		}
+	}
+}
+
+// needsPrune returns whether prune() would remove something from this shard at the given time.
+func (s *clusterTrackingState) needsPrune(now time.Time) bool {
+	return s != nil && !s.pruneAt.IsZero() && now.After(s.pruneAt)
+}
+
+// trackingKey returns a key that identifies a single violation across report versions.
+// The given violation must be expanded from its pattern.
+func trackingKey(templateKind, constraintName string, v doop.Violation) string {
+	hash := sha256.New()
+	hash.Write([]byte(strconv.Itoa(len(templateKind)) + ":" + templateKind))
+	hash.Write([]byte(strconv.Itoa(len(constraintName)) + ":" + constraintName))
+	hash.Write([]byte(patternKey(v)))
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// GetState returns the result of the latest Update(), or nil if there was none yet.
+func (t *ViolationTracker) GetState() *trackingState {
+	return t.state.Load()
+}
+
+// Version returns a string that changes whenever the tracking state changes.
+func (s *trackingState) Version() string {
+	if s == nil {
+		return ""
+	}
+	return s.version
+}
+
+// SetFirstSeen fills the FirstSeen field of all violation instances in the given report.
+func (s *trackingState) SetFirstSeen(report *doop.AggregatedReport) {
+	if s == nil {
+		return
+	}
+	for _, rt := range report.Templates {
+		for _, rc := range rt.Constraints {
+			for _, vg := range rc.ViolationGroups {
+				for idx, instance := range vg.Instances {
+					v := instance.ExpandedFrom(vg.Pattern)
+					shard, exists := s.Clusters[v.ClusterName]
+					if !exists {
+						continue
+					}
+					tv, exists := shard.Violations[trackingKey(rt.Kind, rc.Name, v)]
+					if exists && tv.ResolvedAt == nil {
+						vg.Instances[idx].FirstSeen = &tv.FirstSeen
+					}
+				}
+			}
+		}
+	}
+}
+
+// Changes is the data structure that is returned by GET /v2/changes.
+type Changes struct {
+	Appeared []TrackedViolation `json:"appeared"`
+	Resolved []TrackedViolation `json:"resolved"`
+}
+
+// GetChanges lists all violations that appeared or were resolved at or after the given time.
+// Cluster identities are needed for evaluating cluster_identity filters.
+func (s *trackingState) GetChanges(since time.Time, clusterIdentities map[string]map[string]string, f FilterSet) Changes {
+	result := Changes{
+		Appeared: []TrackedViolation{},
+		Resolved: []TrackedViolation{},
+	}
+	if s == nil {
+		return result
+	}
+
+	for clusterName, shard := range s.Clusters {
+		if !f.MatchClusterIdentity(clusterIdentities[clusterName]) {
+			continue
+		}
+		for _, tv := range shard.Violations {
+			if !f.MatchTemplateKind(tv.TemplateKind) ||
+				!f.MatchConstraintName(tv.ConstraintName) ||
+				!f.MatchSeverity(tv.Severity) ||
+				!f.MatchObjectIdentity(tv.ObjectIdentity) ||
+				!f.MatchObject(tv.Kind, tv.Namespace, tv.Name, tv.Message) {
+				continue
+			}
+			if !tv.FirstSeen.Before(since) {
+				result.Appeared = append(result.Appeared, tv)
+			}
+			if tv.ResolvedAt != nil && !tv.ResolvedAt.Before(since) {
+				result.Resolved = append(result.Resolved, tv)
+			}
+		}
+	}
+
+	slices.SortFunc(result.Appeared, func(lhs, rhs TrackedViolation) int {
+		return cmp.Or(lhs.FirstSeen.Compare(rhs.FirstSeen), compareTrackedViolations(lhs, rhs))
+	})
+	slices.SortFunc(result.Resolved, func(lhs, rhs TrackedViolation) int {
+		return cmp.Or(lhs.ResolvedAt.Compare(*rhs.ResolvedAt), compareTrackedViolations(lhs, rhs))
+	})
+	return result
+}
+
+func compareTrackedViolations(lhs, rhs TrackedViolation) int {
+	return cmp.Or(
+		strings.Compare(lhs.ClusterName, rhs.ClusterName),
+		strings.Compare(lhs.TemplateKind, rhs.TemplateKind),
+		strings.Compare(lhs.ConstraintName, rhs.ConstraintName),
+		strings.Compare(lhs.Kind, rhs.Kind),
+		strings.Compare(lhs.Namespace, rhs.Namespace),
+		strings.Compare(lhs.Name, rhs.Name),
+		strings.Compare(lhs.Message, rhs.Message),
+	)
+}
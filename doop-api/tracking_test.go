@@ -0,0 +1,203 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func makeTrackingReport(clusterName string, podNames ...string) doop.Report {
+	instances := make([]doop.Violation, len(podNames))
+	for idx, name := range podNames {
+		instances[idx] = doop.Violation{Name: name}
+	}
+	report := doop.Report{
+		ClusterIdentity: map[string]string{"name": clusterName},
+		Templates: []doop.ReportForTemplate{{
+			Kind: "GkImageTag",
+			Constraints: []doop.ReportForConstraint{{
+				Name:     "imagetag",
+				Metadata: doop.MetadataForConstraint{Severity: "error"},
+				ViolationGroups: []doop.ViolationGroup{{
+					Pattern:   doop.Violation{Kind: "Pod", Namespace: "default", Message: "image uses latest tag"},
+					Instances: instances,
+				}},
+			}},
+		}},
+	}
+	return report.SetClusterName(clusterName)
+}
+
+func TestViolationTrackingObserve(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(10 * time.Minute)
+	t2 := t0.Add(20 * time.Minute)
+	shard := &clusterTrackingState{Violations: make(map[string]TrackedViolation)}
+
+	// first observation: both violations are new
+	shard.observe(makeTrackingReport("cluster1", "foo", "bar"), t0)
+	assert.Equal(t, len(shard.Violations), 2)
+
+	// "bar" gets resolved
+	shard.observe(makeTrackingReport("cluster1", "foo"), t1)
+
+	pattern := doop.Violation{Kind: "Pod", Namespace: "default", Message: "image uses latest tag"}
+	getTracked := func(podName string) TrackedViolation {
+		v := doop.Violation{Name: podName, ClusterName: "cluster1"}.ExpandedFrom(pattern)
+		return shard.Violations[trackingKey("GkImageTag", "imagetag", v)]
+	}
+	assert.Equal(t, getTracked("foo"), TrackedViolation{
+		ClusterName:    "cluster1",
+		TemplateKind:   "GkImageTag",
+		ConstraintName: "imagetag",
+		Severity:       "error",
+		Kind:           "Pod",
+		Namespace:      "default",
+		Name:           "foo",
+		Message:        "image uses latest tag",
+		FirstSeen:      t0,
+		LastSeen:       t1,
+	})
+	assert.Equal(t, getTracked("bar").ResolvedAt, &t1)
+
+	// when "bar" reappears, it is tracked as a new violation
+	shard.observe(makeTrackingReport("cluster1", "foo", "bar"), t2)
+	assert.Equal(t, getTracked("bar").FirstSeen, t2)
+	assert.Equal(t, getTracked("bar").ResolvedAt, (*time.Time)(nil))
+
+	// violations are forgotten after the retention period
+	shard.prune(t0.Add(trackingRetention))
+	assert.Equal(t, len(shard.Violations), 2)
+	assert.Equal(t, shard.pruneAt, t2.Add(trackingRetention))
+	assert.Equal(t, shard.needsPrune(t2.Add(trackingRetention)), false)
+	assert.Equal(t, shard.needsPrune(t2.Add(trackingRetention+time.Minute)), true)
+	shard.prune(t2.Add(trackingRetention + time.Minute))
+	assert.Equal(t, len(shard.Violations), 0)
+	assert.Equal(t, shard.needsPrune(t2.Add(2*trackingRetention)), false)
+}
+
+func TestViolationTrackingSkipsUnchangedShards(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(10 * time.Minute)
+	now := t1.Add(time.Minute)
+
+	// since there is nothing to do for any of these shards, the tracker must not access Swift (it does not have a container here)
+	tracker := &ViolationTracker{}
+	local := &clusterTrackingState{Violations: make(map[string]TrackedViolation)}
+	local.observe(makeTrackingReport("cluster1", "foo"), t0)
+	local.observedAt = t0
+	local.etag = "0123456789abcdef0123456789abcdef"
+	local.prune(now)
+
+	// the report did not change since the last update
+	objState := objectState{LastModified: t0, Payload: makeTrackingReport("cluster1", "foo"), HasPayload: true}
+	shard := must.ReturnT(tracker.updateShard(t.Context(), "cluster1", local, local.etag, objState, now))(t)
+	assert.Equal(t, shard, local)
+
+	// the latest version of the report cannot be decoded, so the previous version must not be observed again
+	objState = objectState{LastModified: t1, Payload: makeTrackingReport("cluster1", "foo"), HasPayload: true, Error: errUnsupportedSchemaVersion}
+	shard = must.ReturnT(tracker.updateShard(t.Context(), "cluster1", local, local.etag, objState, now))(t)
+	assert.Equal(t, shard, local)
+
+	// the report is gone (e.g. because the cluster was decommissioned), so its violations are retained until they get pruned
+	shard = must.ReturnT(tracker.updateShard(t.Context(), "cluster1", local, local.etag, objectState{}, now))(t)
+	assert.Equal(t, shard, local)
+
+	// there is no shard for this cluster at all
+	shard = must.ReturnT(tracker.updateShard(t.Context(), "cluster2", nil, "", objectState{}, now))(t)
+	assert.Equal(t, shard, (*clusterTrackingState)(nil))
+}
+
+func TestMergeTrackedViolations(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(10 * time.Minute)
+	t2 := t0.Add(20 * time.Minute)
+	t3 := t0.Add(30 * time.Minute)
+
+	// two replicas observed the same appearance at different times: merging takes the union
+	lhs := TrackedViolation{FirstSeen: t0, LastSeen: t1}
+	rhs := TrackedViolation{FirstSeen: t1, LastSeen: t2}
+	assert.Equal(t, mergeTrackedViolations(lhs, rhs), TrackedViolation{FirstSeen: t0, LastSeen: t2})
+
+	// one replica observed the resolution, the other one did not
+	lhs = TrackedViolation{FirstSeen: t0, LastSeen: t1, ResolvedAt: &t2}
+	rhs = TrackedViolation{FirstSeen: t0, LastSeen: t1}
+	assert.Equal(t, mergeTrackedViolations(lhs, rhs), lhs)
+	assert.Equal(t, mergeTrackedViolations(rhs, lhs), lhs)
+
+	// one replica observed the violation again after the other one observed it being resolved
+	rhs = TrackedViolation{FirstSeen: t3, LastSeen: t3}
+	assert.Equal(t, mergeTrackedViolations(lhs, rhs), rhs)
+	assert.Equal(t, mergeTrackedViolations(rhs, lhs), rhs)
+
+	// all of this also works on the level of entire shards, where either side may be missing
+	remote := &clusterTrackingState{Violations: map[string]TrackedViolation{"a": lhs, "b": lhs}}
+	local := &clusterTrackingState{Violations: map[string]TrackedViolation{"b": rhs, "c": rhs}, observedAt: t3}
+	merged := mergeClusterTrackingStates(remote, local)
+	assert.Equal(t, merged.Violations, map[string]TrackedViolation{"a": lhs, "b": rhs, "c": rhs})
+	assert.Equal(t, merged.observedAt, t3)
+	assert.Equal(t, mergeClusterTrackingStates(remote, nil).Violations, remote.Violations)
+	assert.Equal(t, mergeClusterTrackingStates(nil, local).Violations, local.Violations)
+}
+
+func TestViolationTrackingQueries(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(10 * time.Minute)
+	reports := map[string]doop.Report{
+		"cluster1": makeTrackingReport("cluster1", "foo", "bar"),
+		"cluster2": makeTrackingReport("cluster2", "foo"),
+	}
+	state := &trackingState{Clusters: make(map[string]*clusterTrackingState)}
+	for clusterName, report := range reports {
+		state.Clusters[clusterName] = &clusterTrackingState{Violations: make(map[string]TrackedViolation)}
+		state.Clusters[clusterName].observe(report, t0)
+	}
+	reports["cluster1"] = makeTrackingReport("cluster1", "foo")
+	reports["cluster2"] = makeTrackingReport("cluster2", "foo", "qux")
+	for clusterName, report := range reports {
+		state.Clusters[clusterName].observe(report, t1)
+	}
+
+	// first_seen is attached to all instances in GET /v2/violations
+	report := AggregateReports(reports, must.ReturnT(BuildFilterSet(query("")))(t))
+	report.Sort()
+	state.SetFirstSeen(&report)
+	var firstSeen []time.Time
+	for _, vg := range listViolationGroups(report) {
+		for _, instance := range vg.Instances {
+			firstSeen = append(firstSeen, *instance.FirstSeen)
+		}
+	}
+	assert.Equal(t, firstSeen, []time.Time{t0, t0, t1})
+
+	// GET /v2/changes lists appeared and resolved violations, with filters applied
+	clusterIdentities := map[string]map[string]string{
+		"cluster1": reports["cluster1"].ClusterIdentity,
+		"cluster2": reports["cluster2"].ClusterIdentity,
+	}
+	changes := state.GetChanges(t1, clusterIdentities, must.ReturnT(BuildFilterSet(query("")))(t))
+	assert.Equal(t, len(changes.Appeared), 1)
+	assert.Equal(t, changes.Appeared[0].Name, "qux")
+	assert.Equal(t, len(changes.Resolved), 1)
+	assert.Equal(t, changes.Resolved[0].Name, "bar")
+
+	changes = state.GetChanges(t0, clusterIdentities, must.ReturnT(BuildFilterSet(query("cluster_identity.name=cluster1")))(t))
+	assert.Equal(t, len(changes.Appeared), 2)
+	assert.Equal(t, changes.Appeared[0].Name, "bar")
+	assert.Equal(t, changes.Appeared[1].Name, "foo")
+	assert.Equal(t, len(changes.Resolved), 1)
+
+	// without a tracking state (i.e. before the first update), nothing is reported
+	var nilState *trackingState
+	assert.Equal(t, nilState.GetChanges(t0, clusterIdentities, must.ReturnT(BuildFilterSet(query("")))(t)), Changes{
+		Appeared: []TrackedViolation{},
+		Resolved: []TrackedViolation{},
+	})
+}
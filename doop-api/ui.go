@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/sapcc/go-bits/httpapi"
+	"github.com/sapcc/go-bits/logg"
+	"github.com/sapcc/go-bits/must"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+//go:embed ui
+var uiFS embed.FS
+
+var uiTemplate = template.Must(template.New("index.html.tmpl").Funcs(template.FuncMap{
+	"markdown":      renderMarkdown,
+	"expand":        func(v, pattern doop.Violation) doop.Violation { return v.ExpandedFrom(pattern) },
+	"countTemplate": countViolationsInTemplate,
+	"countGroups":   countViolationsInGroups,
+	"groupSize":     violationGroupSize,
+}).ParseFS(uiFS, "ui/index.html.tmpl"))
+
+// uiStaticHandler serves the assets below /ui/static/.
+var uiStaticHandler = http.StripPrefix("/ui/static/", http.FileServerFS(must.Return(fs.Sub(uiFS, "ui/static"))))
+
+// Unless the user asks for something else, the UI shows this many violation groups per page.
+const uiDefaultLimit = 100
+
+// The filter form in the UI has one input for each of these query arguments.
+// Other query arguments (e.g. cluster_identity.$KEY) are carried along in hidden inputs.
+var uiFormKeys = []string{"template_kind", "constraint_name", "severity", "kind", "namespace", "name", "q", "include_stale"}
+
+// uiPage is the data that is rendered into the UI template.
+type uiPage struct {
+	// Only the first value for each of the uiFormKeys.
+	Form map[string]string
+	// All other query arguments (except for the cursor).
+	HiddenParams []uiParam
+	Severities   []string
+	// Set if the query could not be parsed. In this case, Report is empty.
+	Error       string
+	Report      doop.AggregatedReport
+	TotalGroups int
+	FirstURL    string
+	NextURL     string
+	JSONURL     string
+}
+
+type uiParam struct {
+	Key   string
+	Value string
+}
+
+func (a API) handleGetUI(w http.ResponseWriter, r *http.Request) {
+	httpapi.IdentifyEndpoint(r, "/ui/")
+
+	reports, reportErrs := a.Downloader.GetReports()
+	page := buildUIPage(reports, reportErrs, r.URL.Query())
+
+	var buf bytes.Buffer
+	err := uiTemplate.Execute(&buf, page)
+	if err != nil {
+		logg.Error("while rendering UI: %s", err.Error())
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if page.Error == "" {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		logg.Error("while writing UI: %s", err.Error())
+	}
+}
+
+// buildUIPage is the part of handleGetUI that does not depend on the Downloader.
+func buildUIPage(reports map[string]doop.Report, reportErrs map[string]error, rawQuery url.Values) uiPage {
+	// submitting the filter form yields empty values for all fields that were left empty;
+	// those must not be interpreted as filters for empty strings
+	query := make(url.Values, len(rawQuery))
+	for key, values := range rawQuery {
+		for _, value := range values {
+			if value != "" {
+				query[key] = append(query[key], value)
+			}
+		}
+	}
+
+	page := uiPage{
+		Form:       make(map[string]string, len(uiFormKeys)),
+		Severities: []string{"error", "warning", "info", "debug"},
+		JSONURL:    urlWithCursor("/v2/violations", query, ""),
+	}
+	for _, key := range slices.Sorted(maps.Keys(query)) {
+		values := query[key]
+		if key == "cursor" {
+			continue
+		}
+		if slices.Contains(uiFormKeys, key) {
+			page.Form[key] = values[0]
+			values = values[1:]
+		}
+		for _, value := range values {
+			page.HiddenParams = append(page.HiddenParams, uiParam{key, value})
+		}
+	}
+
+	filterSet, err := BuildFilterSet(query)
+	if err != nil {
+		page.Error = err.Error()
+		return page
+	}
+	pagination, err := ParsePagination(query)
+	if err != nil {
+		page.Error = err.Error()
+		return page
+	}
+	if pagination.Limit == 0 {
+		pagination.Limit = uiDefaultLimit
+	}
+
+	page.Report = AggregateReports(reports, filterSet)
+	page.Report.SetErrors(reportErrs)
+	page.Report.Sort()
+	for _, rt := range page.Report.Templates {
+		for _, rc := range rt.Constraints {
+			page.TotalGroups += len(rc.ViolationGroups)
+		}
+	}
+	if query.Has("cursor") {
+		page.FirstURL = urlWithCursor("/ui/", query, "")
+	}
+	if nextCursor := pagination.Apply(&page.Report); nextCursor != "" {
+		page.NextURL = urlWithCursor("/ui/", query, nextCursor)
+	}
+	return page
+}
+
+func countViolationsInTemplate(rt doop.ReportForTemplate) int {
+	result := 0
+	for _, rc := range rt.Constraints {
+		result += countViolationsInGroups(rc.ViolationGroups)
+	}
+	return result
+}
+
+func countViolationsInGroups(groups []doop.ViolationGroup) int {
+	result := 0
+	for _, vg := range groups {
+		result += violationGroupSize(vg)
+	}
+	return result
+}
+
+// violationGroupSize returns the number of instances in the group, including those cut off by `instances_limit`.
+func violationGroupSize(vg doop.ViolationGroup) int {
+	if vg.TotalInstances > 0 {
+		return vg.TotalInstances
+	}
+	return len(vg.Instances)
+}
+
+// uiRedirectHandler sends requests for /ui to /ui/ where the relative links in the UI work.
+func uiRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	target := "/ui/"
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/gatekeeper-addons/internal/doop"
+)
+
+func TestBuildUIPage(t *testing.T) {
+	inputSet := map[string]doop.Report{
+		"cluster1": mustParseJSON[doop.Report](t, "fixtures/input-cluster1.json").SetClusterName("cluster1"),
+		"cluster2": mustParseJSON[doop.Report](t, "fixtures/input-cluster2.json").SetClusterName("cluster2"),
+		"cluster3": mustParseJSON[doop.Report](t, "fixtures/input-cluster3.json").SetClusterName("cluster3"),
+		"cluster4": mustParseJSON[doop.Report](t, "fixtures/input-cluster4.json").SetClusterName("cluster4"),
+	}
+
+	// empty form fields are ignored, and filters that are not in the form are retained in hidden inputs
+	page := buildUIPage(inputSet, nil, query("template_kind=&severity=info&constraint_name=firstconstraint&cluster_identity.number!=three&limit=1"))
+	assert.Equal(t, page.Error, "")
+	assert.Equal(t, page.Form, map[string]string{"severity": "info", "constraint_name": "firstconstraint"})
+	assert.Equal(t, page.HiddenParams, []uiParam{{"cluster_identity.number!", "three"}, {"limit", "1"}})
+	assert.Equal(t, page.TotalGroups, 1)
+	assert.Equal(t, page.FirstURL, "")
+	assert.Equal(t, page.NextURL, "")
+	assert.Equal(t, page.JSONURL, "/v2/violations?cluster_identity.number%21=three&constraint_name=firstconstraint&limit=1&severity=info")
+
+	// pagination works like for GET /v2/violations
+	page = buildUIPage(inputSet, nil, query("limit=2"))
+	assert.Equal(t, page.TotalGroups, 3)
+	assert.Equal(t, len(listViolationGroups(page.Report)), 2)
+	groups := listViolationGroups(page.Report)
+	rt := page.Report.Templates[len(page.Report.Templates)-1]
+	rc := rt.Constraints[len(rt.Constraints)-1]
+	nextCursor := encodeCursor(cursorPosition{rt.Kind, rc.Name, rc.Metadata, groups[len(groups)-1].Pattern})
+	assert.Equal(t, page.FirstURL, "")
+	assert.Equal(t, page.NextURL, "/ui/?cursor="+nextCursor+"&limit=2")
+
+	// on the last page, there is no next page, but a link back to the first page
+	page = buildUIPage(inputSet, nil, query("limit=2&cursor="+nextCursor))
+	assert.Equal(t, len(listViolationGroups(page.Report)), 1)
+	assert.Equal(t, page.FirstURL, "/ui/?limit=2")
+	assert.Equal(t, page.NextURL, "")
+
+	// invalid filters are reported on the page
+	page = buildUIPage(inputSet, nil, query("namespace=~("))
+	if !strings.Contains(page.Error, "invalid regex") {
+		t.Errorf("expected error about invalid regex, but got %q", page.Error)
+	}
+}
+
+func TestRenderUIPage(t *testing.T) {
+	report := doop.Report{
+		ClusterIdentity: map[string]string{"region": "eu"},
+		Templates: []doop.ReportForTemplate{{
+			Kind: "GkImageTag",
+			Constraints: []doop.ReportForConstraint{{
+				Name: "imagetag",
+				Metadata: doop.MetadataForConstraint{
+					Severity:         "error",
+					ConstraintSource: "https://example.com/constraints/imagetag.yaml",
+					Docstring:        "Use **pinned** tags.\n\n<script>alert(1)</script> [x](javascript:alert(1)) [y](javascript:void)",
+				},
+				ViolationGroups: []doop.ViolationGroup{{
+					Pattern:   doop.Violation{Kind: "Pod", Namespace: "kube-system", Message: "image uses <latest> tag"},
+					Instances: []doop.Violation{{Name: "coredns-1"}},
+				}},
+			}},
+		}},
+	}
+	reports := map[string]doop.Report{"cluster1": report.SetClusterName("cluster1")}
+
+	var buf bytes.Buffer
+	must.SucceedT(t, uiTemplate.Execute(&buf, buildUIPage(reports, nil, query("severity=error"))))
+	html := buf.String()
+
+	expectedSnippets := []string{
+		`<option value="error" selected>error</option>`,
+		`<details class="constraint severity-error">`,
+		"<div class=\"docstring\"><p>Use <strong>pinned</strong> tags.</p>\n<p>&lt;script&gt;alert(1)&lt;/script&gt; [x](javascript:alert(1)) y</p>\n</div>",
+		`<a href="https://example.com/constraints/imagetag.yaml" rel="noreferrer">Constraint source</a>`,
+		`image uses &lt;latest&gt; tag`,
+		`<td>cluster1</td>`,
+		`<td>coredns-1</td>`,
+	}
+	for _, snippet := range expectedSnippets {
+		if !strings.Contains(html, snippet) {
+			t.Errorf("expected rendered page to contain %q, but it did not", snippet)
+		}
+	}
+	// the hostile parts of the docstring must be neutralized
+	for _, snippet := range []string{"<script>", `href="javascript:`} {
+		if strings.Contains(html, snippet) {
+			t.Errorf("expected rendered page to not contain %q, but it did", snippet)
+		}
+	}
+
+	// the pagination is shown on the last page as well, to link back to the first page
+	buf.Reset()
+	must.SucceedT(t, uiTemplate.Execute(&buf, buildUIPage(reports, nil, query("limit=1&cursor="+encodeCursor(cursorPosition{})))))
+	html = buf.String()
+	if !strings.Contains(html, `<a href="/ui/?limit=1">First page</a>`) || strings.Contains(html, "Next page") {
+		t.Errorf("expected rendered page to only link to the first page, but got: %s", html)
+	}
+}
+
+func TestUIStaticAssets(t *testing.T) {
+	rec := httptest.NewRecorder()
+	uiStaticHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/static/style.css", nil))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Header().Get("Content-Type"), "text/css; charset=utf-8")
+}
@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package doop
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// AdmissionReportForTemplate appears in type Report. It is similar to type ReportForTemplate,
+// but describes admission requests that Gatekeeper has denied or warned about, instead of audit violations.
+type AdmissionReportForTemplate struct {
+	Kind        string                         `json:"kind"`
+	Constraints []AdmissionReportForConstraint `json:"constraints"`
+}
+
+// Sort sorts all lists in this report in the respective canonical order.
+func (r *AdmissionReportForTemplate) Sort() {
+	slices.SortFunc(r.Constraints, func(lhs, rhs AdmissionReportForConstraint) int {
+		return strings.Compare(lhs.Name, rhs.Name)
+	})
+	for _, rc := range r.Constraints {
+		slices.SortFunc(rc.Events, func(lhs, rhs AdmissionEvent) int {
+			return lhs.CompareTo(rhs)
+		})
+	}
+}
+
+// AdmissionReportForConstraint appears in type AdmissionReportForTemplate.
+type AdmissionReportForConstraint struct {
+	Name string `json:"name"`
+	// Metadata.AuditTimestamp is never filled here.
+	Metadata MetadataForConstraint `json:"metadata"`
+	Events   []AdmissionEvent      `json:"events"`
+}
+
+// AdmissionEvent describes how often a certain user has triggered a certain
+// constraint on a certain object during admission.
+type AdmissionEvent struct {
+	Kind           string            `json:"kind,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Namespace      string            `json:"namespace,omitempty"`
+	Message        string            `json:"message,omitempty"`
+	ObjectIdentity map[string]string `json:"object_identity,omitempty"`
+	User           string            `json:"user,omitempty"`
+	// One of "deny", "warn" or "dryrun".
+	EnforcementAction string `json:"enforcement_action,omitempty"`
+	// How often this event was observed within the collection window.
+	Count int `json:"count"`
+	// RFC3339 timestamp of the most recent observation.
+	LastSeen string `json:"last_seen"`
+	// This field is only set when this AdmissionEvent appears inside an AggregatedReport.
+	// It is written by Report.SetClusterName() at report loading time.
+	ClusterName string `json:"cluster,omitempty"`
+}
+
+// IsSameAs returns whether both events refer to the same constraint action by
+// the same user on the same object, i.e. whether they can be merged by adding
+// up their counts.
+func (e AdmissionEvent) IsSameAs(other AdmissionEvent) bool {
+	return e.Kind == other.Kind &&
+		e.Name == other.Name &&
+		e.Namespace == other.Namespace &&
+		e.User == other.User &&
+		e.EnforcementAction == other.EnforcementAction &&
+		e.ClusterName == other.ClusterName
+}
+
+// MergeFrom adds the count of the other event to this one, and takes the
+// message and object identity from the more recent one of both.
+func (e *AdmissionEvent) MergeFrom(other AdmissionEvent) {
+	e.Count += other.Count
+	//NOTE: RFC3339 timestamps in UTC can be compared lexicographically.
+	if other.LastSeen > e.LastSeen {
+		e.LastSeen = other.LastSeen
+		e.Message = other.Message
+		e.ObjectIdentity = maps.Clone(other.ObjectIdentity)
+	}
+}
+
+// CompareTo is a three-way compare between admission events, following the same conventions as Violation.CompareTo().
+func (e AdmissionEvent) CompareTo(other AdmissionEvent) int {
+	return cmp.Or(
+		strings.Compare(e.Namespace, other.Namespace),
+		strings.Compare(e.Name, other.Name),
+		strings.Compare(e.Kind, other.Kind),
+		strings.Compare(e.User, other.User),
+		strings.Compare(e.EnforcementAction, other.EnforcementAction),
+		strings.Compare(e.ClusterName, other.ClusterName),
+	)
+}
@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package doop
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// DefinitionForTemplate describes how a ConstraintTemplate is defined in a cluster.
+// It appears in type Report, and is used by doop-api to detect drift between clusters.
+//
+// Unlike type ReportForTemplate, this is filled for all templates and constraints, even if they do not have violations.
+type DefinitionForTemplate struct {
+	Kind string `json:"kind"`
+	// Hash is computed from the normalized JSON serialization of Spec (see func HashSpec).
+	Hash string `json:"hash"`
+	// Spec is only filled if doop-analyzer was configured to include full specs in the report.
+	Spec        map[string]any            `json:"spec,omitempty"`
+	Constraints []DefinitionForConstraint `json:"constraints,omitempty"`
+}
+
+// DefinitionForConstraint appears in type DefinitionForTemplate.
+type DefinitionForConstraint struct {
+	Name string `json:"name"`
+	// Hash is computed from the normalized JSON serialization of Spec (see func HashSpec).
+	Hash string `json:"hash"`
+	// Spec is only filled if doop-analyzer was configured to include full specs in the report.
+	Spec map[string]any `json:"spec,omitempty"`
+}
+
+// HashSpec computes a hash over the given object spec. Since json.Marshal()
+// serializes map keys in sorted order, the hash does not depend on key order.
+func HashSpec(spec map[string]any) (string, error) {
+	buf, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("cannot serialize spec for hashing: %w", err)
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
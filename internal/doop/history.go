@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package doop
+
+import (
+	"strings"
+	"time"
+)
+
+// HistoryObjectPrefix is the prefix for the names of all Swift objects containing history snapshots of reports.
+// Objects with this prefix are not considered to be current reports.
+const HistoryObjectPrefix = "history/"
+
+// APIStateObjectPrefix is the prefix for the names of all Swift objects where doop-api stores its own state.
+// Objects with this prefix are not considered to be reports either.
+const APIStateObjectPrefix = "doop-api/"
+
+// HistoryObjectName returns the name of the Swift object containing the
+// history snapshot of the report for the given cluster at the given time.
+func HistoryObjectName(clusterName string, t time.Time) string {
+	return HistoryObjectPrefix + clusterName + "/" + t.UTC().Format(time.RFC3339) + ".json"
+}
+
+// ParseHistoryObjectName is the reverse of HistoryObjectName.
+// If the given object name does not refer to a history snapshot, false is returned.
+func ParseHistoryObjectName(objectName string) (clusterName string, t time.Time, ok bool) {
+	rest, ok := strings.CutPrefix(objectName, HistoryObjectPrefix)
+	if !ok {
+		return "", time.Time{}, false
+	}
+	rest, ok = strings.CutSuffix(rest, ".json")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	idx := strings.LastIndex(rest, "/")
+	if idx <= 0 {
+		return "", time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, rest[idx+1:])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return rest[:idx], t, true
+}
@@ -0,0 +1,40 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package doop
+
+// CurrentSchemaVersion is the version of the report format produced by this version of doop-analyzer.
+// It must be increased whenever the report format changes in a way that older versions of doop-api cannot understand.
+const CurrentSchemaVersion = 1
+
+// ReportMeta appears in type Report. It describes how and when the report was produced.
+type ReportMeta struct {
+	SchemaVersion   int    `json:"schema_version"`
+	AnalyzerVersion string `json:"analyzer_version"`
+	// A hash over the configuration file of doop-analyzer.
+	ConfigHash string `json:"config_hash,omitempty"`
+	// These fields are empty if the respective version could not be detected.
+	GatekeeperVersion string `json:"gatekeeper_version,omitempty"`
+	KubernetesVersion string `json:"kubernetes_version,omitempty"`
+	// RFC3339 timestamp of when collection of this report started.
+	CollectedAt string          `json:"collected_at"`
+	Durations   ReportDurations `json:"durations"`
+}
+
+// ReportDurations appears in type ReportMeta.
+type ReportDurations struct {
+	GatherSecs  float64 `json:"gather_secs"`
+	ProcessSecs float64 `json:"process_secs,omitempty"`
+	// Since a report cannot contain the duration of its own upload,
+	// this refers to the previous upload of a report for the same cluster.
+	UploadSecs float64 `json:"upload_secs,omitempty"`
+}
+
+// SchemaVersion returns the schema version of this report.
+// Reports from before the introduction of schema versions do not have a ReportMeta and are considered to have version 0.
+func (r Report) SchemaVersion() int {
+	if r.Meta == nil {
+		return 0
+	}
+	return r.Meta.SchemaVersion
+}
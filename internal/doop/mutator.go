@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package doop
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// ReportForMutator appears in type Report. It describes the status of a
+// Gatekeeper mutator (any object in the `mutations.gatekeeper.sh` API group).
+type ReportForMutator struct {
+	Kind string             `json:"kind"`
+	Name string             `json:"name"`
+	Pods []MutatorPodStatus `json:"pods"`
+	// This field is only set when this ReportForMutator appears inside an AggregatedMutatorReport.
+	// It is written by Report.SetClusterName() at report loading time.
+	ClusterName string `json:"cluster,omitempty"`
+}
+
+// IsBroken returns whether any Gatekeeper pod reports that this mutator is not enforced or has errors.
+// A mutator that has not been picked up by any Gatekeeper pod at all is also considered broken.
+func (r ReportForMutator) IsBroken() bool {
+	if len(r.Pods) == 0 {
+		return true
+	}
+	for _, ps := range r.Pods {
+		if !ps.Enforced || len(ps.Errors) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// MutatorPodStatus appears in type ReportForMutator.
+type MutatorPodStatus struct {
+	ID       string         `json:"id"`
+	Enforced bool           `json:"enforced"`
+	Errors   []MutatorError `json:"errors,omitempty"`
+}
+
+// MutatorError appears in type MutatorPodStatus.
+type MutatorError struct {
+	Type    string `json:"type,omitempty"`
+	Message string `json:"message"`
+}
+
+// AggregatedMutatorReport is the data structure that doop-api produces for mutators.
+// It aggregates the mutator status from multiple instances of type Report from different clusters.
+type AggregatedMutatorReport struct {
+	ClusterIdentities map[string]map[string]string `json:"cluster_identities"`
+	Mutators          []ReportForMutator           `json:"mutators"`
+}
+
+// Sort sorts all lists in this report in the respective canonical order.
+func (r *AggregatedMutatorReport) Sort() {
+	slices.SortFunc(r.Mutators, func(lhs, rhs ReportForMutator) int {
+		return cmp.Or(
+			strings.Compare(lhs.Kind, rhs.Kind),
+			strings.Compare(lhs.Name, rhs.Name),
+			strings.Compare(lhs.ClusterName, rhs.ClusterName),
+		)
+	})
+}
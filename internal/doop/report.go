@@ -4,17 +4,33 @@
 package doop
 
 import (
+	"cmp"
 	"slices"
 	"strings"
 )
 
 // Report is the data structure that doop-analyzer produces.
 type Report struct {
+	// Meta is nil for reports from older versions of doop-analyzer.
+	Meta            *ReportMeta         `json:"meta,omitempty"`
 	ClusterIdentity map[string]string   `json:"cluster_identity"`
 	Templates       []ReportForTemplate `json:"templates"`
+	// Admissions is only filled if doop-analyzer was configured to collect admission events.
+	Admissions []AdmissionReportForTemplate `json:"admissions,omitempty"`
+	// Definitions is not aggregated by doop-api. It is only used for detecting drift between clusters.
+	Definitions []DefinitionForTemplate `json:"definitions,omitempty"`
+	// Mutators is only filled if doop-analyzer was configured to collect mutators.
+	Mutators []ReportForMutator `json:"mutators,omitempty"`
+	// Redactions counts how many spans were redacted by doop-analyzer, keyed by the name of the redaction rule or detector.
+	Redactions map[string]int `json:"redactions,omitempty"`
+	// Scope is only filled if doop-analyzer was configured to only report on a subset of the cluster.
+	Scope *Scope `json:"scope,omitempty"`
+	// IsStale is not part of the serialization. It is set by doop-api at report loading time
+	// if the report has not been updated for too long.
+	IsStale bool `json:"-"`
 }
 
-// SetClusterName sets the ClusterName field on all Violation objects in this Report.
+// SetClusterName sets the ClusterName field on all Violation, AdmissionEvent and ReportForMutator objects in this Report.
 // This is used at report loading time to prepare the report for aggregation.
 // The self-return is used to shorten setup code in unit tests.
 func (r Report) SetClusterName(clusterName string) Report {
@@ -28,6 +44,16 @@ func (r Report) SetClusterName(clusterName string) Report {
 			}
 		}
 	}
+	for _, t := range r.Admissions {
+		for _, c := range t.Constraints {
+			for idx := range c.Events {
+				c.Events[idx].ClusterName = clusterName
+			}
+		}
+	}
+	for idx := range r.Mutators {
+		r.Mutators[idx].ClusterName = clusterName
+	}
 	return r
 }
 
@@ -40,7 +66,8 @@ type ReportForTemplate struct {
 // Sort sorts all lists in this report in the respective canonical order.
 func (r *ReportForTemplate) Sort() {
 	slices.SortFunc(r.Constraints, func(lhs, rhs ReportForConstraint) int {
-		return strings.Compare(lhs.Name, rhs.Name)
+		// in an AggregatedReport, constraints with the same name can appear multiple times if their metadata differs
+		return cmp.Or(strings.Compare(lhs.Name, rhs.Name), lhs.Metadata.CompareTo(rhs.Metadata))
 	})
 	for idx := range r.Constraints {
 		r.Constraints[idx].Sort()
@@ -67,6 +94,17 @@ type MetadataForConstraint struct {
 	AuditTimestamp string `json:"auditTimestamp,omitempty"`
 }
 
+// CompareTo is a three-way compare between constraint metadata, following the same conventions as Violation.CompareTo().
+func (m MetadataForConstraint) CompareTo(other MetadataForConstraint) int {
+	return cmp.Or(
+		strings.Compare(m.Severity, other.Severity),
+		strings.Compare(m.TemplateSource, other.TemplateSource),
+		strings.Compare(m.ConstraintSource, other.ConstraintSource),
+		strings.Compare(m.Docstring, other.Docstring),
+		strings.Compare(m.AuditTimestamp, other.AuditTimestamp),
+	)
+}
+
 // Sort sorts all lists in this report in the respective canonical order.
 func (r *ReportForConstraint) Sort() {
 	slices.SortFunc(r.ViolationGroups, func(lhs, rhs ViolationGroup) int {
@@ -84,6 +122,33 @@ func (r *ReportForConstraint) Sort() {
 type AggregatedReport struct {
 	ClusterIdentities map[string]map[string]string `json:"cluster_identities"`
 	Templates         []ReportForTemplate          `json:"templates"`
+	Admissions        []AdmissionReportForTemplate `json:"admissions,omitempty"`
+	// Scopes contains the Scope of each cluster report that has one, keyed by cluster name.
+	Scopes map[string]Scope `json:"scopes,omitempty"`
+	// Meta contains the ReportMeta of each cluster report that has one, keyed by cluster name.
+	Meta map[string]ReportMeta `json:"meta,omitempty"`
+	// StaleClusters contains the names of all clusters whose reports are stale,
+	// regardless of whether those reports were included in the aggregation or not.
+	StaleClusters []string `json:"stale_clusters,omitempty"`
+	// Errors contains the error messages for all reports whose latest version could not be loaded, keyed by cluster name.
+	// Those clusters either appear with the last version of their report that could be loaded, or not at all.
+	Errors map[string]string `json:"errors,omitempty"`
+	// Next is only set by doop-api when a paginated response has further pages.
+	// It contains the path and query for requesting the next page.
+	Next string `json:"next,omitempty"`
+}
+
+// SetErrors fills the Errors field from the given errors. Nil errors are ignored.
+func (r *AggregatedReport) SetErrors(errs map[string]error) {
+	for clusterName, err := range errs {
+		if err == nil {
+			continue
+		}
+		if r.Errors == nil {
+			r.Errors = make(map[string]string)
+		}
+		r.Errors[clusterName] = err.Error()
+	}
 }
 
 // Sort sorts all lists in this report in the respective canonical order.
@@ -94,4 +159,11 @@ func (r *AggregatedReport) Sort() {
 	for idx := range r.Templates {
 		r.Templates[idx].Sort()
 	}
+	slices.SortFunc(r.Admissions, func(lhs, rhs AdmissionReportForTemplate) int {
+		return strings.Compare(lhs.Kind, rhs.Kind)
+	})
+	for idx := range r.Admissions {
+		r.Admissions[idx].Sort()
+	}
+	slices.Sort(r.StaleClusters)
 }
@@ -0,0 +1,21 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package doop
+
+// Scope appears in type Report if doop-analyzer was configured to only report on a subset of the cluster.
+// An object is in scope if it matches all non-empty fields of Include, and none of the non-empty fields of Exclude.
+type Scope struct {
+	Include ScopeSelector `json:"include,omitzero"`
+	Exclude ScopeSelector `json:"exclude,omitzero"`
+}
+
+// ScopeSelector appears in type Scope.
+type ScopeSelector struct {
+	// Regexes for namespace names.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// A label selector for namespaces, in the same syntax as for `kubectl get -l`.
+	NamespaceLabels string   `json:"namespace_labels,omitempty"`
+	Kinds           []string `json:"kinds,omitempty"`
+	Constraints     []string `json:"constraints,omitempty"`
+}
@@ -5,13 +5,18 @@ package doop
 
 import (
 	"maps"
+	"slices"
 	"strings"
+	"time"
 )
 
 // ViolationGroup describes a set of one or more policy violations that follow a common pattern.
 type ViolationGroup struct {
 	Pattern   Violation   `json:"pattern"`
 	Instances []Violation `json:"instances"`
+	// TotalInstances is only set by doop-api when the `instances_limit` query argument is given.
+	// It counts all instances in this group, including those that were cut off by the limit.
+	TotalInstances int `json:"total_instances,omitempty"`
 }
 
 // Violation describes a single policy violation, or the common pattern within a ViolationGroup.
@@ -26,6 +31,9 @@ type Violation struct {
 	// This field is only set when this Violation appears as a ViolationGroup instance inside an AggregatedReport.
 	// It is written by Report.SetClusterName() at report loading time.
 	ClusterName string `json:"cluster,omitempty"`
+	// This field is only set by doop-api on ViolationGroup instances when violation tracking is enabled.
+	// It is ignored by all methods of this type.
+	FirstSeen *time.Time `json:"first_seen,omitempty"`
 }
 
 // Cloned returns a deep copy of this Violation.
@@ -71,6 +79,32 @@ func (v Violation) DifferenceTo(pattern Violation) Violation {
 	return result
 }
 
+// ExpandedFrom is the inverse of DifferenceTo: It returns a copy of this
+// violation, with all cleared-out fields filled from the pattern.
+// The ObjectIdentity map is shared with the input, not cloned.
+func (v Violation) ExpandedFrom(pattern Violation) Violation {
+	result := v
+	if result.Kind == "" {
+		result.Kind = pattern.Kind
+	}
+	if result.Name == "" {
+		result.Name = pattern.Name
+	}
+	if result.Namespace == "" {
+		result.Namespace = pattern.Namespace
+	}
+	if result.Message == "" {
+		result.Message = pattern.Message
+	}
+	if result.ObjectIdentity == nil {
+		result.ObjectIdentity = pattern.ObjectIdentity
+	}
+	if result.ClusterName == "" {
+		result.ClusterName = pattern.ClusterName
+	}
+	return result
+}
+
 // CompareTo is a three-way compare between violations. As per the usual convention,
 // 0 means `v == other`, negative means `v < other`, and positive means `v > other`.
 func (v Violation) CompareTo(other Violation) int {
@@ -90,5 +124,27 @@ func (v Violation) CompareTo(other Violation) int {
 	if cmp != 0 {
 		return cmp
 	}
-	return strings.Compare(v.ClusterName, other.ClusterName)
+	cmp = strings.Compare(v.ClusterName, other.ClusterName)
+	if cmp != 0 {
+		return cmp
+	}
+	// object identities are compared last, so that this is a total order (i.e. 0 is only returned if IsEqualTo() is true)
+	return compareStringMaps(v.ObjectIdentity, other.ObjectIdentity)
+}
+
+// compareStringMaps is a three-way compare between maps, comparing keys and values in key order.
+func compareStringMaps(lhs, rhs map[string]string) int {
+	lhsKeys := slices.Sorted(maps.Keys(lhs))
+	rhsKeys := slices.Sorted(maps.Keys(rhs))
+	for idx := range min(len(lhsKeys), len(rhsKeys)) {
+		cmp := strings.Compare(lhsKeys[idx], rhsKeys[idx])
+		if cmp != 0 {
+			return cmp
+		}
+		cmp = strings.Compare(lhs[lhsKeys[idx]], rhs[rhsKeys[idx]])
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return len(lhsKeys) - len(rhsKeys)
 }